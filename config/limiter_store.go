@@ -0,0 +1,149 @@
+package config
+
+import (
+	"container/list"
+	"fmt"
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+var (
+	RATE_LIMIT_MAX_ENTRIES    = 100_000
+	RATE_LIMIT_IDLE_TTL       = 10 * time.Minute
+	RATE_LIMIT_SWEEP_INTERVAL = time.Minute
+)
+
+// LimiterStore decides whether a key may take one more request under limit.
+type LimiterStore interface {
+	Allow(key string, limit RateLimit) (bool, error)
+	Stats() (LimiterStats, error)
+}
+
+// MemoryLimiterStore keeps token bucket limiters in a LimiterCache of this
+// process. Each replica keeps its own budget.
+type MemoryLimiterStore struct {
+	Cache *LimiterCache
+}
+
+func (store MemoryLimiterStore) Allow(key string, limit RateLimit) (bool, error) {
+	// The limit is part of the key so a changed limit starts a fresh limiter
+	key = fmt.Sprintf("%s/%d:%s", limit.Interval, limit.Requests, key)
+	limiter := store.Cache.Get(key, func() *rate.Limiter {
+		// Define the rate limit (tokens per second)
+		rateLimit := rate.Limit(float64(limit.Requests) / limit.Interval.Seconds())
+		return rate.NewLimiter(rateLimit, limit.Requests)
+	})
+	return limiter.Allow(), nil
+}
+
+func (store MemoryLimiterStore) Stats() (LimiterStats, error) {
+	return store.Cache.Stats(), nil
+}
+
+// LimiterStats describes a limiter store. Stores fill in what they track:
+// the in-memory cache reports all fields, shared stores only Store and Size.
+type LimiterStats struct {
+	Store     string
+	Size      int
+	Capacity  int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Expired   uint64
+}
+
+type limiterEntry struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// LimiterCache keeps one limiter per key in LRU order. The least recently used
+// entry is evicted once MaxEntries is reached, and a sweeper drops entries
+// idle for longer than IdleTTL.
+type LimiterCache struct {
+	MaxEntries int
+	IdleTTL    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	stats   LimiterStats
+}
+
+func NewLimiterCache(maxEntries int, idleTTL time.Duration) *LimiterCache {
+	return &LimiterCache{
+		MaxEntries: maxEntries,
+		IdleTTL:    idleTTL,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+// Get returns the limiter of key, creating it with newLimiter when missing.
+func (cache *LimiterCache) Get(key string, newLimiter func() *rate.Limiter) *rate.Limiter {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := time.Now()
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*limiterEntry)
+		entry.lastSeen = now
+		cache.order.MoveToFront(element)
+		cache.stats.Hits++
+		return entry.limiter
+	}
+	cache.stats.Misses++
+	for cache.MaxEntries > 0 && cache.order.Len() >= cache.MaxEntries {
+		cache.remove(cache.order.Back())
+		cache.stats.Evictions++
+	}
+	entry := &limiterEntry{key: key, limiter: newLimiter(), lastSeen: now}
+	cache.entries[key] = cache.order.PushFront(entry)
+	return entry.limiter
+}
+
+// Sweep drops the entries idle for longer than IdleTTL.
+func (cache *LimiterCache) Sweep() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cutoff := time.Now().Add(-cache.IdleTTL)
+	removed := 0
+	for element := cache.order.Back(); element != nil; element = cache.order.Back() {
+		if element.Value.(*limiterEntry).lastSeen.After(cutoff) {
+			break
+		}
+		cache.remove(element)
+		removed++
+	}
+	cache.stats.Expired += uint64(removed)
+	return removed
+}
+
+func (cache *LimiterCache) StartSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if removed := cache.Sweep(); removed > 0 {
+				stats := cache.Stats()
+				fmt.Printf("Middleware => Dropped %d idle limiters, %d of %d left\n", removed, stats.Size, stats.Capacity)
+			}
+		}
+	}()
+}
+
+func (cache *LimiterCache) Stats() LimiterStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	stats := cache.stats
+	stats.Store = "memory"
+	stats.Size = cache.order.Len()
+	stats.Capacity = cache.MaxEntries
+	return stats
+}
+
+func (cache *LimiterCache) remove(element *list.Element) {
+	cache.order.Remove(element)
+	delete(cache.entries, element.Value.(*limiterEntry).key)
+}
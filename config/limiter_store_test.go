@@ -0,0 +1,96 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func newTestLimiter() *rate.Limiter {
+	return rate.NewLimiter(1, 1)
+}
+
+func TestLimiterCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLimiterCache(2, time.Minute)
+	first := cache.Get("a", newTestLimiter)
+	cache.Get("b", newTestLimiter)
+	// Touching "a" makes "b" the least recently used entry
+	if cache.Get("a", newTestLimiter) != first {
+		t.Fatal("expected the cached limiter of a")
+	}
+	cache.Get("c", newTestLimiter)
+
+	stats := cache.Stats()
+	if stats.Size != 2 || stats.Capacity != 2 || stats.Evictions != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats.Hits != 1 || stats.Misses != 3 {
+		t.Fatalf("unexpected hits and misses %+v", stats)
+	}
+	if cache.Get("a", newTestLimiter) != first {
+		t.Error("a was evicted instead of b")
+	}
+	misses := cache.Stats().Misses
+	cache.Get("b", newTestLimiter)
+	if cache.Stats().Misses != misses+1 {
+		t.Error("b should have been evicted")
+	}
+}
+
+func TestLimiterCacheSweepsIdleEntries(t *testing.T) {
+	cache := NewLimiterCache(10, 20*time.Millisecond)
+	cache.Get("idle", newTestLimiter)
+	time.Sleep(30 * time.Millisecond)
+	cache.Get("active", newTestLimiter)
+
+	if removed := cache.Sweep(); removed != 1 {
+		t.Fatalf("expected 1 idle entry to be swept, got %d", removed)
+	}
+	stats := cache.Stats()
+	if stats.Size != 1 || stats.Expired != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestMemoryLimiterStoreAllow(t *testing.T) {
+	store := MemoryLimiterStore{Cache: NewLimiterCache(10, time.Minute)}
+	limit := RateLimit{Requests: 2, Interval: time.Hour}
+	for i := 0; i < 2; i++ {
+		if allowed, _ := store.Allow("ip", limit); !allowed {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+	if allowed, _ := store.Allow("ip", limit); allowed {
+		t.Error("the third request should be limited")
+	}
+	if allowed, _ := store.Allow("other", limit); !allowed {
+		t.Error("other keys have their own budget")
+	}
+	// A changed limit starts a fresh limiter
+	if allowed, _ := store.Allow("ip", RateLimit{Requests: 5, Interval: time.Hour}); !allowed {
+		t.Error("a changed limit should start a fresh budget")
+	}
+	if stats, _ := store.Stats(); stats.Store != "memory" || stats.Size != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := map[string]RateLimit{
+		"30/1m": {Requests: 30, Interval: time.Minute},
+		" 5/1h": {Requests: 5, Interval: time.Hour},
+		"0":     {},
+	}
+	for value, expected := range tests {
+		limit, err := ParseRateLimit(value)
+		if err != nil || limit != expected {
+			t.Errorf("ParseRateLimit(%q) = %v, %v", value, limit, err)
+		}
+	}
+	for _, value := range []string{"", "30", "-1/1m", "30/0s", "a/1m", "30/x"} {
+		if _, err := ParseRateLimit(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
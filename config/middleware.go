@@ -1,22 +1,77 @@
 package config
 
 import (
+	"core/utils"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-var limiterMap sync.Map
+var (
+	limiters     *LimiterCache
+	limitersOnce sync.Once
+	limiterStore LimiterStore
+)
+
+// OnRateLimitExceeded, when set, is told about every client that hits the limit.
+var OnRateLimitExceeded func(ip string)
+
+// RateLimit allows Requests per Interval. A zero RateLimit allows everything.
+type RateLimit struct {
+	Requests int
+	Interval time.Duration
+}
+
+// ParseRateLimit reads limits written as REQUESTS/INTERVAL, e.g. 30/1m, or 0 to
+// turn the limit off.
+func ParseRateLimit(value string) (RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "0" {
+		return RateLimit{}, nil
+	}
+	requestsText, intervalText, found := strings.Cut(value, "/")
+	requests, err := strconv.Atoi(requestsText)
+	if !found || err != nil || requests <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	interval, err := time.ParseDuration(intervalText)
+	if err != nil || interval <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	return RateLimit{Requests: requests, Interval: interval}, nil
+}
+
+func GetEnvRateLimit(key string, defaultValue RateLimit) RateLimit {
+	limit, err := ParseRateLimit(utils.GetEnvString(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return limit
+}
+
+func (limit RateLimit) Enabled() bool {
+	return limit.Requests > 0 && limit.Interval > 0
+}
+
+func (limit RateLimit) String() string {
+	if !limit.Enabled() {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", limit.Requests, limit.Interval)
+}
 
 func RateLimitMiddleware(interval time.Duration, requestsPerInterval int) gin.HandlerFunc {
+	return RouteRateLimitMiddleware("default", RateLimit{Requests: requestsPerInterval, Interval: interval})
+}
+
+// RouteRateLimitMiddleware limits each client IP separately for every route group.
+func RouteRateLimitMiddleware(group string, limit RateLimit) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		limiter := getLimiter(ip, interval, requestsPerInterval)
-		if !limiter.Allow() {
-			fmt.Printf("Middleware => Rate limit exceeded for IP %s\n", ip)
+		if !AllowRouteRequest(c, group, limit) {
 			c.AbortWithStatus(http.StatusTooManyRequests)
 			return
 		}
@@ -24,16 +79,62 @@ func RateLimitMiddleware(interval time.Duration, requestsPerInterval int) gin.Ha
 	}
 }
 
-func getLimiter(ip string, interval time.Duration, requestsPerInterval int) *rate.Limiter {
-	actual, exists := limiterMap.Load(ip)
-	if exists {
-		return actual.(*rate.Limiter)
+// AllowRouteRequest takes one request of the client IP from the budget of the
+// route group. Handlers that must set headers before answering 429 call it
+// themselves instead of using RouteRateLimitMiddleware.
+func AllowRouteRequest(c *gin.Context, group string, limit RateLimit) bool {
+	ip := c.ClientIP()
+	if AllowRequest("route:"+group+":"+ip, limit) {
+		return true
+	}
+	fmt.Printf("Middleware => Rate limit of %s exceeded for IP %s\n", group, ip)
+	if OnRateLimitExceeded != nil {
+		OnRateLimitExceeded(ip)
 	}
+	return false
+}
 
-	// Define the rate limit (tokens per second)
-	rateLimit := rate.Limit(float64(requestsPerInterval) / interval.Seconds())
-	limiter := rate.NewLimiter(rateLimit, requestsPerInterval)
-	limiterMap.Store(ip, limiter)
+// AllowRequest takes one request from the budget of key under limit. When the
+// store fails the request is let through rather than locking everyone out.
+func AllowRequest(key string, limit RateLimit) bool {
+	if !limit.Enabled() {
+		return true
+	}
+	allowed, err := GetLimiterStore().Allow(key, limit)
+	if err != nil {
+		fmt.Printf("Middleware => Error checking rate limit of %s: %v\n", key, err)
+		return true
+	}
+	return allowed
+}
+
+func SetLimiterStore(store LimiterStore) {
+	limiterStore = store
+}
+
+// GetLimiterStore returns the configured store, or the in-memory store.
+func GetLimiterStore() LimiterStore {
+	if limiterStore != nil {
+		return limiterStore
+	}
+	return MemoryLimiterStore{Cache: GetLimiters()}
+}
+
+// GetLimiters returns the shared limiter cache, sized from the environment on
+// first use.
+func GetLimiters() *LimiterCache {
+	limitersOnce.Do(func() {
+		limiters = NewLimiterCache(
+			utils.GetEnvInt("RATE_LIMIT_MAX_ENTRIES", RATE_LIMIT_MAX_ENTRIES),
+			utils.GetEnvDuration("RATE_LIMIT_IDLE_TTL", RATE_LIMIT_IDLE_TTL),
+		)
+		limiters.StartSweeper(RATE_LIMIT_SWEEP_INTERVAL)
+	})
+	return limiters
+}
 
-	return limiter
+// GetRateLimitStats reports on the configured store. Only the in-memory store
+// creates the limiter cache, so a shared store doesn't start an idle sweeper.
+func GetRateLimitStats() (LimiterStats, error) {
+	return GetLimiterStore().Stats()
 }
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type failingStore struct {
+	calls int
+}
+
+func (store *failingStore) Allow(key string, limit RateLimit) (bool, error) {
+	store.calls++
+	return false, errors.New("store is down")
+}
+
+func (store *failingStore) Stats() (LimiterStats, error) {
+	return LimiterStats{}, errors.New("store is down")
+}
+
+func TestAllowRequestFailsOpen(t *testing.T) {
+	store := &failingStore{}
+	SetLimiterStore(store)
+	defer SetLimiterStore(nil)
+
+	if !AllowRequest("key", RateLimit{Requests: 1, Interval: time.Minute}) {
+		t.Error("a failing store should let requests through")
+	}
+	if !AllowRequest("key", RateLimit{}) || store.calls != 1 {
+		t.Error("a disabled limit should not reach the store")
+	}
+	if _, err := GetRateLimitStats(); err == nil {
+		t.Error("stats should come from the configured store")
+	}
+}
+
+func TestRouteRateLimitMiddleware(t *testing.T) {
+	SetLimiterStore(MemoryLimiterStore{Cache: NewLimiterCache(10, time.Minute)})
+	defer SetLimiterStore(nil)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", RouteRateLimitMiddleware("test", RateLimit{Requests: 1, Interval: time.Hour}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	statuses := []int{}
+	for _, ip := range []string{"192.0.2.1", "192.0.2.1", "192.0.2.2"} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = ip + ":1234"
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		statuses = append(statuses, recorder.Code)
+	}
+	expected := []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK}
+	for i := range expected {
+		if statuses[i] != expected[i] {
+			t.Fatalf("statuses = %v, want %v", statuses, expected)
+		}
+	}
+}
+
+func TestAllowRouteRequestKeepsHandlerHeaders(t *testing.T) {
+	SetLimiterStore(MemoryLimiterStore{Cache: NewLimiterCache(10, time.Minute)})
+	defer SetLimiterStore(nil)
+	exceeded := []string{}
+	OnRateLimitExceeded = func(ip string) { exceeded = append(exceeded, ip) }
+	defer func() { OnRateLimitExceeded = nil }()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", func(c *gin.Context) {
+		c.Header("Access-Control-Allow-Origin", "https://example.com")
+		if !AllowRouteRequest(c, "submit", RateLimit{Requests: 1, Interval: time.Hour}) {
+			c.Status(http.StatusTooManyRequests)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	for i, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		request := httptest.NewRequest(http.MethodPost, "/", nil)
+		request.RemoteAddr = "192.0.2.1:1234"
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != expected {
+			t.Fatalf("request %d: status %d, want %d", i, recorder.Code, expected)
+		}
+		if recorder.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+			t.Errorf("request %d: the CORS header set before the limit check was lost", i)
+		}
+	}
+	if len(exceeded) != 1 || exceeded[0] != "192.0.2.1" {
+		t.Errorf("exceeded = %v, want the limited IP once", exceeded)
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"core/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var CLIENT_IP_HEADERS = []string{"X-Forwarded-For", "X-Real-IP"}
+
+// ConfigureTrustedProxies makes c.ClientIP() read the client IP header only
+// when the request comes from one of the TRUSTED_PROXIES CIDRs. Without
+// trusted proxies the header is ignored and the connection's address is used,
+// so clients can't spoof their IP. Rate limiting, spam checks and submission
+// logging all rely on c.ClientIP().
+func ConfigureTrustedProxies(router *gin.Engine) error {
+	router.ForwardedByClientIP = true
+	router.RemoteIPHeaders = utils.GetEnvList("CLIENT_IP_HEADER", CLIENT_IP_HEADERS)
+	return router.SetTrustedProxies(utils.GetEnvList("TRUSTED_PROXIES", nil))
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"core/models"
+	"core/services"
+	"core/utils"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// CreateApiSubmission accepts JSON submissions from backends and apps. The
+// form's secret API key replaces the origin and captcha checks of browser
+// submissions; rate limits still apply.
+func CreateApiSubmission(c *gin.Context) {
+	formToken, err := models.GetFormTokenByUuid(utils.GetUUIDFromString(c.Param("data")))
+	if err != nil || !services.AuthenticateApiKey(formToken, c.GetHeader("Authorization")) {
+		c.Header("WWW-Authenticate", `Bearer realm="formy"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"ok": false, "error": "API key is not valid."})
+		return
+	}
+
+	if !services.AllowFormSubmission(formToken) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"ok": false, "error": "Too many submissions. Please try again later."})
+		return
+	}
+
+	if c.ContentType() != gin.MIMEJSON {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"ok": false, "error": "Submissions must be sent as application/json."})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MAX_REQUEST_BODY_SIZE)
+	JSONData, postedOrder, err := readJSONData(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false, "error": "Request body is not a valid JSON object."})
+		return
+	}
+
+	if fieldErrors := services.ValidateSubmission(formToken, JSONData); len(fieldErrors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"ok": false, "error": "Please correct the highlighted fields.", "errors": fieldErrorsByName(fieldErrors)})
+		return
+	}
+
+	submission := models.Submission{
+		FormTokenUuid: formToken.Uuid,
+		Fields:        JSONData,
+		FieldOrder:    services.ResolveFieldOrder(formToken, JSONData, postedOrder),
+		IP:            c.ClientIP(),
+		UserAgent:     c.Request.UserAgent(),
+		Status:        models.SubmissionStatusPending,
+	}
+	if services.ScoreSubmission(formToken, &submission) {
+		submission.Status = models.SubmissionStatusQuarantined
+	}
+	if err := services.DispatchSubmission(formToken, &submission); err != nil {
+		log.Println("Error saving submission:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "error": "Error occurred while submitting a form."})
+		return
+	}
+	if submission.Status == models.SubmissionStatusQuarantined {
+		services.RecordBlockedSubmission(formToken, models.SpamReasonQuarantined)
+	}
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "id": submission.ID, "message": "Form submitted successfully."})
+}
@@ -1,78 +1,174 @@
 package api
 
 import (
+	"core/config"
 	"core/models"
 	"core/services"
 	"core/utils"
-	"fmt"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"html"
+	"log"
 	"net/http"
-	"net/url"
 	"os"
-	"slices"
 	"strings"
 )
 
 var (
-	CCLIST_MAX_AMOUNT      = 2
-	TELEGRAM_MESSAGE_LIMIT = 4096
+	MAX_REQUEST_BODY_SIZE int64 = 1 << 20
+	MAX_MULTIPART_MEMORY  int64 = 1 << 20
 )
 
 func CreateFormData(c *gin.Context) {
 	data := c.Param("data")
 	toUuid := utils.GetUUIDFromString(data)
-	if toUuid == uuid.Nil {
+	var formToken *models.FormToken
+	var err error
+	if toUuid != uuid.Nil {
+		formToken, err = models.GetFormTokenByUuid(toUuid)
+	}
+	if toUuid == uuid.Nil || err != nil {
+		// The error reveals nothing about any form, so every origin may read it
+		services.SetCORSHeaders(c, utils.ParseOriginURL(c.Request.Header.Get("Origin")))
+		if !config.AllowRouteRequest(c, "submit", services.RATE_LIMIT_SUBMIT) {
+			showTooManyRequestsPage(c)
+			return
+		}
 		showErrorPage(c, "Token is not valid.")
 		return
 	}
-	formToken, err := models.GetFormTokenByUuid(toUuid)
-	if err != nil {
-		return
+
+	// CORS headers go out before any other check, so scripts on allowed domains can read the errors
+	originUrl, originErr := services.CheckFormOrigin(formToken, c)
+	if originErr == nil {
+		services.SetCORSHeaders(c, originUrl)
 	}
 
-	origin := utils.GetRequestOrigin(c)
-	if origin == "" {
-		showErrorPage(c, "Request origin is not valid.")
+	if !config.AllowRouteRequest(c, "submit", services.RATE_LIMIT_SUBMIT) {
+		showTooManyRequestsPage(c)
+		return
+	}
+	if !services.AllowFormSubmission(formToken) {
+		services.Altcha.Record(c.ClientIP(), formToken.Uuid.String(), services.SIGNAL_RATE_LIMIT)
+		showTooManyRequestsPage(c)
 		return
 	}
 
-	domains := services.GetDomainsName(formToken.UserID)
-	if !slices.Contains(domains, origin) {
-		showErrorPage(c, "This is not an allowed domain.")
+	if originErr != nil {
+		showErrorPage(c, originErr.Error())
 		return
 	}
+	origin := ""
+	if originUrl != nil {
+		origin = originUrl.Hostname()
+	}
 
-	err = c.Request.ParseForm()
+	uploadLimits := services.GetUploadLimits(formToken)
+	JSONData, postedOrder, err := readRequestData(c, MAX_REQUEST_BODY_SIZE+int64(uploadLimits.MaxFiles)*uploadLimits.MaxFileSize)
 	if err != nil {
 		showErrorPage(c, "Error occurred while submitting a form.")
 		return
 	}
-	JSONData := readFormData(c.Request.Form)
+	if c.Request.MultipartForm != nil {
+		defer c.Request.MultipartForm.RemoveAll()
+	}
 
-	altchaValue, exists := JSONData["altcha"]
-	if exists {
-		altchaParam, ok := altchaValue.(string)
-		if !ok || altchaParam == "" {
+	// Spam caught by the honeypot or timestamp checks looks like a success to the sender
+	if reason := services.CheckSpamHeuristics(formToken, JSONData); reason != "" {
+		services.RecordBlockedSubmission(formToken, reason)
+		services.Altcha.Record(c.ClientIP(), formToken.Uuid.String(), services.SIGNAL_SPAM)
+		showSuccessPage(c, JSONData, 0)
+		return
+	}
+
+	if err := services.VerifyFormCaptcha(formToken, JSONData, c.ClientIP()); err != nil {
+		showErrorPage(c, err.Error())
+		return
+	}
+
+	if fieldErrors := services.ValidateSubmission(formToken, JSONData); len(fieldErrors) > 0 {
+		showValidationErrors(c, fieldErrors)
+		return
+	}
+
+	files, err := services.StoreUploadedFiles(formToken, c.Request.MultipartForm)
+	if err != nil {
+		var uploadErr *services.UploadError
+		if errors.As(err, &uploadErr) {
+			showErrorPage(c, uploadErr.Message)
+		} else {
+			log.Println("Error storing uploaded files:", err)
 			showErrorPage(c, "Error occurred while submitting a form.")
-			return
 		}
-		if !services.IsCaptchaValid(altchaParam) {
-			showErrorPage(c, "Captcha is not valid.")
-			return
-		}
-		delete(JSONData, "altcha")
+		return
 	}
 
-	go sendToTelegram(formToken, JSONData)
+	submission := models.Submission{
+		FormTokenUuid: formToken.Uuid,
+		Fields:        JSONData,
+		FieldOrder:    services.ResolveFieldOrder(formToken, JSONData, postedOrder),
+		Origin:        origin,
+		IP:            c.ClientIP(),
+		UserAgent:     c.Request.UserAgent(),
+		Status:        models.SubmissionStatusPending,
+		Files:         files,
+	}
+	// Quarantined submissions are stored without being delivered until the owner releases them
+	if services.ScoreSubmission(formToken, &submission) {
+		submission.Status = models.SubmissionStatusQuarantined
+	}
+	if err := services.DispatchSubmission(formToken, &submission); err != nil {
+		services.DeleteStoredFiles(files)
+		log.Println("Error saving submission:", err)
+		showErrorPage(c, "Error occurred while submitting a form.")
+		return
+	}
 
-	if val, ok := JSONData["_next"]; ok {
-		if len(strings.TrimSpace(val.(string))) > 0 {
-			c.Redirect(http.StatusMovedPermanently, val.(string))
-			c.Abort()
-			return
+	if submission.Status == models.SubmissionStatusQuarantined {
+		services.RecordBlockedSubmission(formToken, models.SpamReasonQuarantined)
+		services.Altcha.Record(submission.IP, formToken.Uuid.String(), services.SIGNAL_SPAM)
+	} else {
+		services.Altcha.Record(submission.IP, formToken.Uuid.String(), services.SIGNAL_SUBMISSION)
+	}
+	showSuccessPage(c, JSONData, submission.ID)
+}
+
+// FormPreflight answers CORS preflights with the form's own allowed domains
+// instead of a blanket "*".
+func FormPreflight(c *gin.Context) {
+	c.Header("Vary", "Origin")
+	formToken, err := models.GetFormTokenByUuid(utils.GetUUIDFromString(c.Param("data")))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	originUrl := utils.ParseOriginURL(c.Request.Header.Get("Origin"))
+	if originUrl == nil || !services.MatchesAllowedDomain(services.GetFormDomainsName(formToken), originUrl) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	services.SetPreflightHeaders(c, originUrl)
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func showSuccessPage(c *gin.Context, JSONData models.JSONData, submissionID uint64) {
+	next := html.UnescapeString(strings.TrimSpace(JSONData.String("_next")))
+	if wantsJSON(c) {
+		response := gin.H{"ok": true, "message": "Form submitted successfully."}
+		if submissionID != 0 {
+			response["id"] = submissionID
+		}
+		if next != "" {
+			response["next"] = next
 		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if next != "" {
+		c.Redirect(http.StatusMovedPermanently, next)
+		c.Abort()
+		return
 	}
 	c.HTML(http.StatusOK, "form-verification.html", gin.H{
 		"text":     "Form submitted successfully.",
@@ -80,102 +176,50 @@ func CreateFormData(c *gin.Context) {
 	})
 }
 
-func showErrorPage(c *gin.Context, errorText string) {
+func wantsJSON(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON
+}
+
+func showValidationErrors(c *gin.Context, fieldErrors []services.FieldError) {
+	errorText := "Please correct the highlighted fields."
+	if wantsJSON(c) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"ok": false, "error": errorText, "errors": fieldErrorsByName(fieldErrors)})
+		return
+	}
 	c.HTML(http.StatusOK, "form-verification.html", gin.H{
 		"text":     errorText,
+		"errors":   fieldErrors,
 		"formyUrl": os.Getenv("BASE_URL"),
 	})
 }
 
-func readFormData(form url.Values) map[string]interface{} {
-	JSONData := map[string]interface{}{}
-	for key, values := range form {
-		for _, value := range values {
-			JSONData[key] = html.EscapeString(strings.TrimSpace(value))
-		}
-	}
-	return JSONData
-}
-
-func sendToTelegram(formToken *models.FormToken, formData map[string]interface{}) {
-	subject := "New form submission"
-	if val, ok := formData["_subject"]; ok {
-		if len(strings.TrimSpace(val.(string))) > 0 {
-			subject = val.(string)
-		}
-	}
-	telegramBody := createTelegramBody(subject, formData)
-	for _, message := range telegramBody {
-		services.SendTelegramMessage(formToken.ChatID, message)
-	}
-
-	if val, ok := formData["_cc"]; ok {
-		if len(strings.TrimSpace(val.(string))) > 0 {
-			ccList := strings.SplitN(formData["_cc"].(string), ",", CCLIST_MAX_AMOUNT+1)
-			for i, to := range ccList {
-				if i >= CCLIST_MAX_AMOUNT {
-					break
-				}
-				formToken, err := models.GetFormTokenByUuid(utils.GetUUIDFromString(to))
-				if err != nil {
-					return
-				}
-				for _, message := range telegramBody {
-					services.SendTelegramMessage(formToken.ChatID, message)
-				}
-			}
-		}
-	}
-}
-
-func createTelegramBody(subject string, formValues map[string]interface{}) []string {
-	var messageBuilder strings.Builder
-	messageBuilder.WriteString(fmt.Sprintf("<b>%s</b>\n\n", subject))
-	var hashtags []string
-	messageBuilder.WriteString("Submitted fields:\n")
-	for key := range formValues {
-		if !strings.HasPrefix(key, "_") {
-			hashtags = append(hashtags, "#"+strings.ReplaceAll(key, " ", "_"))
-		}
-	}
-	messageBuilder.WriteString(strings.Join(hashtags, " ") + "\n\n")
-	for key, value := range formValues {
-		if strings.HasPrefix(key, "_") {
-			continue
-		}
-		messageBuilder.WriteString(fmt.Sprintf("<b>#%s:</b>\n%v\n\n", key, value))
-	}
-	fullMessage := messageBuilder.String()
-	var messages []string
-	for len(fullMessage) > 0 {
-		if len(fullMessage) > TELEGRAM_MESSAGE_LIMIT {
-			messages = append(messages, fullMessage[:TELEGRAM_MESSAGE_LIMIT])
-			fullMessage = fullMessage[TELEGRAM_MESSAGE_LIMIT:]
-		} else {
-			messages = append(messages, fullMessage)
-			break
-		}
+func fieldErrorsByName(fieldErrors []services.FieldError) gin.H {
+	errorsByField := gin.H{}
+	for _, fieldError := range fieldErrors {
+		errorsByField[fieldError.Field] = fieldError.Message
 	}
-	return messages
+	return errorsByField
 }
 
-func createHTMLBody(formValues map[string]interface{}) string {
-	template := ReadMailTemplate("/views/mails/form-template.html")
-	tableData := ""
-	for key, value := range formValues {
-		if strings.HasPrefix(key, "_") {
-			continue
-		}
-		tableData += fmt.Sprintf("<tr><td>%s</td><td>%s</td><tr>", key, value)
+func showErrorPage(c *gin.Context, errorText string) {
+	if wantsJSON(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false, "error": errorText})
+		return
 	}
-	return strings.Replace(template, "%s", tableData, -1)
+	c.HTML(http.StatusOK, "form-verification.html", gin.H{
+		"text":     errorText,
+		"formyUrl": os.Getenv("BASE_URL"),
+	})
 }
 
-func ReadMailTemplate(path string) string {
-	pwd, _ := os.Getwd()
-	templateFile, err := os.ReadFile(pwd + path)
-	if err != nil {
-		fmt.Print("Error on reading email template.")
+func showTooManyRequestsPage(c *gin.Context) {
+	errorText := "Too many submissions. Please try again later."
+	if wantsJSON(c) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"ok": false, "error": errorText})
+		return
 	}
-	return string(templateFile)
+	c.HTML(http.StatusTooManyRequests, "form-verification.html", gin.H{
+		"text":     errorText,
+		"formyUrl": os.Getenv("BASE_URL"),
+	})
 }
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"bytes"
+	"core/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// readRequestData parses the submitted fields and returns them together with
+// the order their names were first posted in.
+func readRequestData(c *gin.Context, maxBodySize int64) (models.JSONData, []string, error) {
+	switch c.ContentType() {
+	case gin.MIMEJSON:
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MAX_REQUEST_BODY_SIZE)
+		return readJSONData(c.Request.Body)
+	case gin.MIMEMultipartPOSTForm:
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize)
+		return readMultipartData(c)
+	default:
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MAX_REQUEST_BODY_SIZE)
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err := c.Request.ParseForm(); err != nil {
+			return nil, nil, err
+		}
+		order := queryKeyOrder(string(body))
+		order = append(order, queryKeyOrder(c.Request.URL.RawQuery)...)
+		return readFormData(c.Request.Form), order, nil
+	}
+}
+
+func queryKeyOrder(query string) []string {
+	var order []string
+	for _, pair := range strings.FieldsFunc(query, func(r rune) bool { return r == '&' || r == ';' }) {
+		key, _, _ := strings.Cut(pair, "=")
+		if key, err := url.QueryUnescape(key); err == nil && key != "" {
+			order = append(order, key)
+		}
+	}
+	return order
+}
+
+func readMultipartData(c *gin.Context) (models.JSONData, []string, error) {
+	_, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return nil, nil, errors.New("missing multipart boundary")
+	}
+
+	// Part names are collected from a copy of the stream while ParseMultipartForm
+	// reads it, because multipart.Form keeps fields in maps.
+	pipeReader, pipeWriter := io.Pipe()
+	orderChan := make(chan []string, 1)
+	go func() {
+		var order []string
+		reader := multipart.NewReader(pipeReader, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			order = append(order, part.FormName())
+		}
+		io.Copy(io.Discard, pipeReader)
+		orderChan <- order
+	}()
+
+	body := c.Request.Body
+	c.Request.Body = io.NopCloser(io.TeeReader(body, pipeWriter))
+	err = c.Request.ParseMultipartForm(MAX_MULTIPART_MEMORY)
+	pipeWriter.Close()
+	order := <-orderChan
+	c.Request.Body = body
+	if err != nil {
+		return nil, nil, err
+	}
+	// Form holds the query string fields too, like in the urlencoded branch
+	order = append(order, queryKeyOrder(c.Request.URL.RawQuery)...)
+	return readFormData(c.Request.Form), order, nil
+}
+
+func readJSONData(body io.Reader) (models.JSONData, []string, error) {
+	var payload json.RawMessage
+	if err := json.NewDecoder(body).Decode(&payload); err != nil {
+		return nil, nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(payload), []byte("{")) {
+		return nil, nil, errors.New("json body must be an object")
+	}
+	JSONData := models.JSONData{}
+	var order []string
+	if err := flattenJSONValue(JSONData, &order, "", payload); err != nil {
+		return nil, nil, err
+	}
+	return JSONData, order, nil
+}
+
+// flattenJSONValue turns nested objects and arrays into flat keys such as
+// "address.city" and "items[0].name" so they can be shown like form fields.
+// Arrays of plain values are kept as multi-value fields.
+func flattenJSONValue(JSONData models.JSONData, order *[]string, key string, raw json.RawMessage) error {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case bytes.HasPrefix(raw, []byte("{")):
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			var childValue json.RawMessage
+			if err := decoder.Decode(&childValue); err != nil {
+				return err
+			}
+			childKey := token.(string)
+			if key != "" {
+				childKey = key + "." + childKey
+			}
+			if err := flattenJSONValue(JSONData, order, childKey, childValue); err != nil {
+				return err
+			}
+		}
+	case bytes.HasPrefix(raw, []byte("[")):
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return err
+		}
+		if values, ok := scalarJSONValues(items); ok {
+			setField(JSONData, order, key, values)
+			return nil
+		}
+		for i, item := range items {
+			if err := flattenJSONValue(JSONData, order, fmt.Sprintf("%s[%d]", key, i), item); err != nil {
+				return err
+			}
+		}
+	default:
+		value, err := scalarJSONValue(raw)
+		if err != nil {
+			return err
+		}
+		setField(JSONData, order, key, value)
+	}
+	return nil
+}
+
+func scalarJSONValues(items []json.RawMessage) ([]string, bool) {
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		item = bytes.TrimSpace(item)
+		if bytes.HasPrefix(item, []byte("{")) || bytes.HasPrefix(item, []byte("[")) {
+			return nil, false
+		}
+		value, err := scalarJSONValue(item)
+		if err != nil {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	return values, true
+}
+
+func scalarJSONValue(raw json.RawMessage) (string, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+	if value == nil {
+		return "", nil
+	}
+	return html.EscapeString(strings.TrimSpace(fmt.Sprint(value))), nil
+}
+
+func setField(JSONData models.JSONData, order *[]string, key string, value interface{}) {
+	if _, exists := JSONData[key]; !exists {
+		*order = append(*order, key)
+	}
+	JSONData[key] = value
+}
+
+func readFormData(form url.Values) map[string]interface{} {
+	JSONData := map[string]interface{}{}
+	for key, values := range form {
+		var escapedValues []string
+		for _, value := range values {
+			escapedValues = append(escapedValues, html.EscapeString(strings.TrimSpace(value)))
+		}
+		if len(escapedValues) == 1 {
+			JSONData[key] = escapedValues[0]
+		} else {
+			JSONData[key] = escapedValues
+		}
+	}
+	return JSONData
+}
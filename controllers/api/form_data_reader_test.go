@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRequestContext(t *testing.T, target string, contentType string, body []byte) *gin.Context {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", contentType)
+	return c
+}
+
+func TestFlattenJSONValue(t *testing.T) {
+	body := `{"name": " Jane <b> ", "address": {"city": "Oslo", "zip": 150},
+		"tags": ["a", "b"], "items": [{"sku": "x1"}, {"sku": "x2"}], "note": null, "agree": true}`
+	JSONData, order, err := readJSONData(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOrder := []string{"name", "address.city", "address.zip", "tags", "items[0].sku", "items[1].sku", "note", "agree"}
+	if !reflect.DeepEqual(order, expectedOrder) {
+		t.Errorf("order = %v, want %v", order, expectedOrder)
+	}
+	expectedValues := map[string][]string{
+		"name":         {"Jane &lt;b&gt;"},
+		"address.city": {"Oslo"},
+		"address.zip":  {"150"},
+		"tags":         {"a", "b"},
+		"items[1].sku": {"x2"},
+		"note":         {""},
+		"agree":        {"true"},
+	}
+	for key, expected := range expectedValues {
+		if values := JSONData.Values(key); !reflect.DeepEqual(values, expected) {
+			t.Errorf("%s = %v, want %v", key, values, expected)
+		}
+	}
+}
+
+func TestReadJSONDataRejectsNonObjects(t *testing.T) {
+	for _, body := range []string{`["a"]`, `"text"`, `{"a": `} {
+		if _, _, err := readJSONData(strings.NewReader(body)); err == nil {
+			t.Errorf("expected %s to be rejected", body)
+		}
+	}
+}
+
+func TestReadRequestDataMergesQueryFields(t *testing.T) {
+	var multipartBody bytes.Buffer
+	writer := multipart.NewWriter(&multipartBody)
+	writer.WriteField("name", "Jane")
+	writer.WriteField("email", "jane@example.com")
+	writer.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{"urlencoded", "application/x-www-form-urlencoded", []byte("name=Jane&email=jane%40example.com")},
+		{"multipart", writer.FormDataContentType(), multipartBody.Bytes()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newRequestContext(t, "/form?_next=https%3A%2F%2Fexample.com%2Fthanks", test.contentType, test.body)
+			JSONData, order, err := readRequestData(c, MAX_REQUEST_BODY_SIZE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if JSONData.String("name") != "Jane" || JSONData.String("email") != "jane@example.com" {
+				t.Errorf("body fields are missing: %v", JSONData)
+			}
+			if JSONData.String("_next") != "https://example.com/thanks" {
+				t.Errorf("query field is missing: %v", JSONData)
+			}
+			if expected := []string{"name", "email", "_next"}; !reflect.DeepEqual(order, expected) {
+				t.Errorf("order = %v, want %v", order, expected)
+			}
+		})
+	}
+}
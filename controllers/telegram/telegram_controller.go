@@ -1,16 +1,22 @@
 package telegram
 
 import (
+	"core/config"
 	"core/models"
 	"core/services"
 	"core/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/asaskevich/govalidator"
 	"github.com/gin-gonic/gin"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gorm.io/gorm"
 	"log"
 	"net/http"
+	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -45,6 +51,54 @@ func handleCommand(update tgbotapi.Update) {
 		handleAddDomainCommand(update)
 	case "domains_list":
 		handleDomainsListCommand(update)
+	case "set_email":
+		handleSetEmailCommand(update)
+	case "remove_email":
+		handleRemoveEmailCommand(update)
+	case "set_webhook":
+		handleSetWebhookCommand(update)
+	case "remove_webhook":
+		handleRemoveWebhookCommand(update)
+	case "webhook_log":
+		handleWebhookLogCommand(update)
+	case "add_route":
+		handleAddRouteCommand(update)
+	case "routes_list":
+		handleRoutesListCommand(update)
+	case "set_upload_limits":
+		handleSetUploadLimitsCommand(update)
+	case "set_field_order":
+		handleSetFieldOrderCommand(update)
+	case "set_schema":
+		handleSetSchemaCommand(update)
+	case "set_spam_protection":
+		handleSetSpamProtectionCommand(update)
+	case "spam_stats":
+		handleSpamStatsCommand(update)
+	case "set_spam_threshold":
+		handleSetSpamThresholdCommand(update)
+	case "set_spam_keywords":
+		handleSetSpamKeywordsCommand(update)
+	case "quarantine":
+		handleQuarantineCommand(update)
+	case "set_captcha":
+		handleSetCaptchaCommand(update)
+	case "set_rate_limit":
+		handleSetRateLimitCommand(update)
+	case "attach_domain":
+		handleAttachDomainCommand(update)
+	case "detach_domain":
+		handleDetachDomainCommand(update)
+	case "verify_domain":
+		handleVerifyDomainCommand(update)
+	case "set_origin_policy":
+		handleSetOriginPolicyCommand(update)
+	case "rate_limit_stats":
+		handleRateLimitStatsCommand(update)
+	case "rotate_api_key":
+		handleRotateApiKeyCommand(update)
+	case "revoke_api_key":
+		handleRevokeApiKeyCommand(update)
 	default:
 		handleUnknownCommand(update)
 	}
@@ -85,7 +139,30 @@ func handleStartCommand(update tgbotapi.Update) {
 		"To get a new form token, type: \\/get\\_token FORM\\_NAME\n"+
 		"To view all your form tokens, type: \\/tokens\\_list\n"+
 		"To add a new domain, type: \\/add\\_domain DOMAIN\n"+
-		"To view all your allowed domains, type: \\/domains\\_list\n",
+		"To view all your allowed domains, type: \\/domains\\_list\n"+
+		"To receive a form by email, type: \\/set\\_email FORM\\_NAME EMAIL\n"+
+		"To stop receiving a form by email, type: \\/remove\\_email FORM\\_NAME\n"+
+		"To push a form to your webhook, type: \\/set\\_webhook FORM\\_NAME URL\n"+
+		"To stop pushing a form to your webhook, type: \\/remove\\_webhook FORM\\_NAME\n"+
+		"To view recent webhook deliveries, type: \\/webhook\\_log FORM\\_NAME\n"+
+		"To deliver a form to another channel, type: \\/add\\_route FORM\\_NAME CHANNEL TARGET\n"+
+		"To view routes of a form, type: \\/routes\\_list FORM\\_NAME\n"+
+		"To limit file uploads of a form, type: \\/set\\_upload\\_limits FORM\\_NAME MAX\\_FILES MAX\\_SIZE\\_MB \\[MIME\\_TYPES\\]\n"+
+		"To order the fields of a form, type: \\/set\\_field\\_order FORM\\_NAME FIELD1,FIELD2\n"+
+		"To validate the fields of a form, type: \\/set\\_schema FORM\\_NAME SCHEMA\\_JSON\n"+
+		"To configure spam protection of a form, type: \\/set\\_spam\\_protection FORM\\_NAME HONEYPOT\\_FIELD MIN\\_SECONDS\n"+
+		"To view blocked spam of a form, type: \\/spam\\_stats FORM\\_NAME\n"+
+		"To set the spam score threshold of a form, type: \\/set\\_spam\\_threshold FORM\\_NAME SCORE\n"+
+		"To block extra keywords in a form, type: \\/set\\_spam\\_keywords FORM\\_NAME KEYWORDS\n"+
+		"To review quarantined submissions of a form, type: \\/quarantine FORM\\_NAME\n"+
+		"To set the captcha policy of a form, type: \\/set\\_captcha FORM\\_NAME POLICY \\[PROVIDER\\]\n"+
+		"To limit how often a form accepts submissions, type: \\/set\\_rate\\_limit FORM\\_NAME LIMIT\n"+
+		"To allow a domain for one form only, type: \\/attach\\_domain FORM\\_NAME DOMAIN\n"+
+		"To remove a domain from a form, type: \\/detach\\_domain FORM\\_NAME DOMAIN\n"+
+		"To prove you own a domain, type: \\/verify\\_domain DOMAIN\n"+
+		"To accept or reject submissions without an origin, type: \\/set\\_origin\\_policy FORM\\_NAME accept\\|reject\n"+
+		"To create or rotate the API key of a form, type: \\/rotate\\_api\\_key FORM\\_NAME\n"+
+		"To revoke the API key of a form, type: \\/revoke\\_api\\_key FORM\\_NAME\n",
 		update.Message.From.FirstName)
 
 	services.Bot.Send(msg)
@@ -155,6 +232,9 @@ func handleTokensListCommand(update tgbotapi.Update) {
 	services.Bot.Send(msg)
 }
 
+// domainPattern accepts an optional scheme, a "*." wildcard, IDN hosts and an optional port
+const domainPattern = `((?:https?://)?(?:\*\.)?(?:[\p{L}\p{N}-]+(?:\.[\p{L}\p{N}-]+)+|localhost)(?::\d{1,5})?)`
+
 func handleAddDomainCommand(update tgbotapi.Update) {
 	chatID := update.Message.Chat.ID
 	userID := update.Message.From.ID
@@ -174,7 +254,7 @@ func handleAddDomainCommand(update tgbotapi.Update) {
 	}
 
 	command := update.Message.Text
-	commandRegex := regexp.MustCompile(`^/add_domain\s+([a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)+|localhost|127\.0\.0\.1)$`)
+	commandRegex := regexp.MustCompile(`^/add_domain\s+` + domainPattern + `$`)
 	matches := commandRegex.FindStringSubmatch(command)
 	if len(matches) == 0 {
 		msg.Text = fmt.Sprintf("Invalid command format\\.\n\nTo add allowed domain run: \\/add\\_domain DOMAIN")
@@ -186,7 +266,8 @@ func handleAddDomainCommand(update tgbotapi.Update) {
 	if ok, msgText := services.CreateUserAllowedDomain(user, domain); !ok {
 		msg.Text = msgText
 	} else {
-		msg.Text = fmt.Sprintf("✅ Domain created successfully\\.\n\nSend \\/domains\\_list to see domains\\.")
+		msg.Text = "✅ Domain created successfully\\.\n\n" + tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, msgText) +
+			"\n\nSend \\/domains\\_list to see domains\\."
 	}
 	services.Bot.Send(msg)
 }
@@ -203,8 +284,13 @@ func handleDomainsListCommand(update tgbotapi.Update) {
 		services.Bot.Send(msg)
 		return
 	}
-	msg.Text = "*Your domains:*\nSelect a domain below to see details\\."
+	msg.Text = "*Your domains:*\nDomains attached to a form show its name, the others work for every form without " +
+		"domains of its own\\. Select a domain below to see details\\."
 	domains := services.GetDomains(user.ID)
+	formNames := map[string]string{}
+	for _, formToken := range services.GetFormTokens(user) {
+		formNames[formToken.Uuid.String()] = formToken.Name
+	}
 	if len(domains) == 0 {
 		msg.Text = "You don't have any allowed domains yet\\.\n\nTo add allowed domain run: \\/add\\_domain DOMAIN"
 		services.Bot.Send(msg)
@@ -212,13 +298,856 @@ func handleDomainsListCommand(update tgbotapi.Update) {
 	}
 	var rows [][]tgbotapi.InlineKeyboardButton
 	for _, domain := range domains {
-		button := tgbotapi.NewInlineKeyboardButtonData(domain.Name, fmt.Sprintf("domain_%d", domain.ID))
+		label := domain.Name
+		if domain.FormTokenUuid != nil {
+			label += " (" + formNames[domain.FormTokenUuid.String()] + ")"
+		}
+		button := tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("domain_%d", domain.ID))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	services.Bot.Send(msg)
+}
+
+func handleAttachDomainCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/attach_domain\s+(\S+)\s+` + domainPattern + `$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo allow a domain for one form only run: \\/attach\\_domain FORM\\_NAME DOMAIN\n\n" +
+			"A form with attached domains accepts only those, the others accept your account\\-wide domains\\."
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	if ok, msgText := services.CreateFormAllowedDomain(formToken, matches[2]); !ok {
+		msg.Text = msgText
+	} else {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ %s attached to %s. The form now accepts only its own domains.\n\n%s",
+			matches[2], formToken.Name, msgText)) + "\n\nSend \\/domains\\_list to see domains\\."
+	}
+	services.Bot.Send(msg)
+}
+
+func handleDetachDomainCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/detach_domain\s+(\S+)\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo remove a domain from a form run: \\/detach\\_domain FORM\\_NAME DOMAIN"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	if services.DeleteFormAllowedDomain(formToken, matches[2]) == 0 {
+		msg.Text = `Domain is not attached to this form\! Send \/domains\_list to see domains\.`
+	} else {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ %s removed from %s.", matches[2], formToken.Name))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleVerifyDomainCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/verify_domain\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo prove you own a domain run: \\/verify\\_domain DOMAIN"
+		services.Bot.Send(msg)
+		return
+	}
+	verified, err := services.VerifyUserDomain(user, matches[1])
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		msg.Text = `Domain not found\! Send \/domains\_list to see domains\.`
+	} else if err != nil {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, "❌ Verification failed: "+err.Error())
+	} else {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ %s is verified (%d records). Forms accept submissions from it now.",
+			matches[1], verified))
+	}
+	services.Bot.Send(msg)
+}
+
+func getVerifiedUser(update tgbotapi.Update, msg *tgbotapi.MessageConfig) *models.User {
+	user, err := models.GetByTelegramUserId(uint64(update.Message.From.ID))
+	if err != nil {
+		msg.Text = `User not found\! Start the bot first\.`
+		services.Bot.Send(msg)
+		return nil
+	}
+	if user.VerifiedAt.IsZero() {
+		msg.Text = `User not validated\!`
+		services.Bot.Send(msg)
+		return nil
+	}
+	return user
+}
+
+func handleSetEmailCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/set_email\s+(\S+)\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 || !govalidator.IsEmail(matches[2]) {
+		msg.Text = "Invalid command format\\.\n\nTo receive a form by email run: \\/set\\_email FORM\\_NAME EMAIL"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	if ok, msgText := services.CreateFormRoute(formToken, models.DeliveryChannelEmail, matches[2]); !ok {
+		msg.Text = msgText
+	} else {
+		msg.Text = fmt.Sprintf("✅ Submissions of *%s* will be sent to %s\\.",
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name),
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, matches[2]))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleRemoveEmailCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/remove_email\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo stop receiving a form by email run: \\/remove\\_email FORM\\_NAME"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	services.DeleteFormRoutes(formToken, models.DeliveryChannelEmail)
+	msg.Text = fmt.Sprintf("✅ Submissions of *%s* will no longer be sent by email\\.",
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name))
+	services.Bot.Send(msg)
+}
+
+func handleSetWebhookCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/set_webhook\s+(\S+)\s+(https?://\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 || !govalidator.IsURL(matches[2]) {
+		msg.Text = "Invalid command format\\.\n\nTo push a form to your webhook run: \\/set\\_webhook FORM\\_NAME URL"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	services.DeleteFormRoutes(formToken, models.DeliveryChannelWebhook)
+	if ok, msgText := services.CreateFormRoute(formToken, models.DeliveryChannelWebhook, matches[2]); !ok {
+		msg.Text = msgText
+	} else {
+		msg.Text = fmt.Sprintf("✅ Submissions of *%s* will be posted to your webhook\\.\n\n%s",
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name), webhookSecretText(formToken))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleRemoveWebhookCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/remove_webhook\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo stop pushing a form to your webhook run: \\/remove\\_webhook FORM\\_NAME"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	services.DeleteFormRoutes(formToken, models.DeliveryChannelWebhook)
+	msg.Text = fmt.Sprintf("✅ Submissions of *%s* will no longer be posted to a webhook\\.",
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name))
+	services.Bot.Send(msg)
+}
+
+func webhookSecretText(formToken *models.FormToken) string {
+	return fmt.Sprintf("Verify the `X-Formy-Signature` header \\(HMAC\\-SHA256 of `TIMESTAMP.BODY`\\) with this secret:\n`%s`",
+		formToken.WebhookSecret)
+}
+
+func handleAddRouteCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/add_route\s+(\S+)\s+(\S+)\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo deliver a form to another channel run: \\/add\\_route FORM\\_NAME CHANNEL TARGET"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	channel := strings.ToLower(matches[2])
+	if ok, msgText := services.CreateFormRoute(formToken, channel, matches[3]); !ok {
+		msg.Text = msgText
+	} else {
+		msg.Text = fmt.Sprintf("✅ Route created successfully\\.\n\nSend \\/routes\\_list %s to see routes\\.",
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name))
+		if channel == models.DeliveryChannelWebhook {
+			msg.Text += "\n\n" + webhookSecretText(formToken)
+		}
+	}
+	services.Bot.Send(msg)
+}
+
+func handleRoutesListCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/routes_list\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo view routes of a form run: \\/routes\\_list FORM\\_NAME"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	routes := models.GetFormRoutes(formToken.Uuid)
+	msg.Text = fmt.Sprintf("*Routes of %s:*\nSubmissions always reach this chat\\. Select a route below to see details\\.",
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name))
+	if len(routes) == 0 {
+		msg.Text = "This form doesn't have any extra routes yet\\.\n\nTo deliver a form to another channel run: \\/add\\_route FORM\\_NAME CHANNEL TARGET"
+		services.Bot.Send(msg)
+		return
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, route := range routes {
+		button := tgbotapi.NewInlineKeyboardButtonData(route.Channel+": "+route.Target, fmt.Sprintf("route_%d", route.ID))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	services.Bot.Send(msg)
+}
+
+func handleWebhookLogCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/webhook_log\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo view recent webhook deliveries run: \\/webhook\\_log FORM\\_NAME"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	deliveries := models.GetFormDeliveries(formToken.Uuid, models.DeliveryChannelWebhook, 10)
+	if len(deliveries) == 0 {
+		msg.Text = "There are no webhook deliveries for this form yet\\."
+		services.Bot.Send(msg)
+		return
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("*Recent webhook deliveries of %s:*\n\n", tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name)))
+	for _, delivery := range deliveries {
+		line := fmt.Sprintf("#%d %s, submission #%d, %d attempts, %s",
+			delivery.ID, delivery.Status, delivery.SubmissionID, delivery.Attempts, delivery.CreatedAt.Format("2006-01-02 15:04"))
+		if delivery.LastError != "" {
+			line += "\n  " + delivery.LastError
+		}
+		builder.WriteString(tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, line) + "\n")
+	}
+	msg.Text = builder.String()
+	services.Bot.Send(msg)
+}
+
+func handleSetUploadLimitsCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/set_upload_limits\s+(\S+)\s+(\d+)\s+(\d+)(?:\s+(\S+))?$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo limit file uploads of a form run: \\/set\\_upload\\_limits FORM\\_NAME MAX\\_FILES MAX\\_SIZE\\_MB \\[MIME\\_TYPES\\]\n\n" +
+			"For example: \\/set\\_upload\\_limits contact 2 5 image/\\*,application/pdf"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	maxFiles, _ := strconv.Atoi(matches[2])
+	maxSizeMB, _ := strconv.ParseInt(matches[3], 10, 64)
+	if maxFiles > 10 || maxSizeMB > 20 {
+		msg.Text = `Forms accept at most 10 files of 20 MB each\.`
+		services.Bot.Send(msg)
+		return
+	}
+	maxFileSize := maxSizeMB << 20
+	formToken.UploadMaxFiles = &maxFiles
+	formToken.UploadMaxFileSize = &maxFileSize
+	formToken.UploadMimeTypes = matches[4]
+	if err := formToken.Save(); err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+	} else {
+		limits := services.GetUploadLimits(formToken)
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ %s accepts %d files of up to %d MB (%s).",
+			formToken.Name, limits.MaxFiles, limits.MaxFileSize>>20, strings.Join(limits.MimeTypes, ", ")))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleSetFieldOrderCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/set_field_order\s+(\S+)(?:\s+(.+))?$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo order the fields of a form run: \\/set\\_field\\_order FORM\\_NAME FIELD1,FIELD2\n" +
+			"Leave the fields out to use the order they are posted in\\."
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	fields := utils.SplitList(matches[2])
+	formToken.FieldOrder = strings.Join(fields, ",")
+	if len(formToken.FieldOrder) > 1000 {
+		msg.Text = `Field order is too long\!`
+		services.Bot.Send(msg)
+		return
+	}
+	if err := formToken.Save(); err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+	} else if len(fields) == 0 {
+		msg.Text = fmt.Sprintf("✅ Fields of *%s* will be shown in the order they are posted\\.",
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name))
+	} else {
+		msg.Text = fmt.Sprintf("✅ Fields of *%s* will be shown as: %s",
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name),
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, strings.Join(fields, ", ")))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleSetSchemaCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`(?s)^/set_schema\s+(\S+)(?:\s+(\{.*\}))?\s*$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo validate the fields of a form run: \\/set\\_schema FORM\\_NAME SCHEMA\\_JSON\n\n" +
+			"For example:\n`{\"email\": {\"required\": true, \"type\": \"email\"}, \"message\": {\"min_length\": 10}}`\n\n" +
+			"Rules: required, type \\(email, phone, url, number, integer\\), min\\_length, max\\_length, min, max, pattern, enum, message\\.\n" +
+			"Leave the schema out to remove validation\\."
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	// Telegram clients may turn straight quotes into typographic ones
+	schema := strings.NewReplacer("“", `"`, "”", `"`).Replace(strings.TrimSpace(matches[2]))
+	if _, err := services.ParseValidationSchema(schema); err != nil {
+		msg.Text = "Schema is not valid: " + tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, err.Error())
+		services.Bot.Send(msg)
+		return
+	}
+	formToken.ValidationSchema = schema
+	if err := formToken.Save(); err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+	} else if formToken.ValidationSchema == "" {
+		msg.Text = fmt.Sprintf("✅ Submissions of *%s* are no longer validated\\.",
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name))
+	} else {
+		msg.Text = fmt.Sprintf("✅ Submissions of *%s* will be validated\\.",
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleSetSpamProtectionCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/set_spam_protection\s+(\S+)\s+([A-Za-z0-9_\-\[\]]{1,50})\s+(\d{1,3})$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo configure spam protection of a form run: \\/set\\_spam\\_protection FORM\\_NAME HONEYPOT\\_FIELD MIN\\_SECONDS\n\n" +
+			"For example: \\/set\\_spam\\_protection contact \\_gotcha 3"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	minSubmitSeconds, _ := strconv.Atoi(matches[3])
+	formToken.HoneypotField = matches[2]
+	formToken.MinSubmitSeconds = &minSubmitSeconds
+	if err := formToken.Save(); err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+	} else {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf(
+			"✅ %s drops submissions that fill the hidden %s field or arrive less than %d seconds after the form was rendered.",
+			formToken.Name, formToken.HoneypotField, minSubmitSeconds))
+		if minSubmitSeconds > 0 {
+			msg.Text += tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, "\n\nSubmissions without the signed _ts timestamp are dropped too.")
+		}
+	}
+	services.Bot.Send(msg)
+}
+
+func handleSpamStatsCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/spam_stats\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo view blocked spam of a form run: \\/spam\\_stats FORM\\_NAME"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	msg.Text = fmt.Sprintf("*Spam of %s*\n\n%s",
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name),
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, services.GetSpamStatsText(formToken)))
+	services.Bot.Send(msg)
+}
+
+func handleSetSpamThresholdCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/set_spam_threshold\s+(\S+)\s+(\d{1,3})$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo set the spam score threshold of a form run: \\/set\\_spam\\_threshold FORM\\_NAME SCORE\n\n" +
+			"Submissions scoring at least SCORE are quarantined, 0 turns scoring off\\."
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	threshold, _ := strconv.Atoi(matches[2])
+	formToken.SpamThreshold = &threshold
+	if err := formToken.Save(); err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+	} else if threshold == 0 {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ Spam scoring is off for %s.", formToken.Name))
+	} else {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ %s quarantines submissions scoring %d or more.", formToken.Name, threshold))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleSetSpamKeywordsCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/set_spam_keywords\s+(\S+)(?:\s+(.+))?$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo block extra keywords in a form run: \\/set\\_spam\\_keywords FORM\\_NAME KEYWORDS\n\n" +
+			"For example: \\/set\\_spam\\_keywords contact free money,guest post\n" +
+			"Run it without keywords to remove them\\."
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	keywords := utils.SplitList(matches[2])
+	formToken.SpamKeywords = strings.Join(keywords, ",")
+	if err := formToken.Save(); err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+	} else if len(keywords) == 0 {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ Extra keywords of %s removed.", formToken.Name))
+	} else {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ %s blocks: %s", formToken.Name, strings.Join(keywords, ", ")))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleQuarantineCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/quarantine\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo review quarantined submissions of a form run: \\/quarantine FORM\\_NAME"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	submissions := models.GetQuarantinedSubmissions(formToken.Uuid, services.QUARANTINE_LIST_MAX_SIZE)
+	if len(submissions) == 0 {
+		msg.Text = "There are no quarantined submissions for this form\\."
+		services.Bot.Send(msg)
+		return
+	}
+	msg.Text = fmt.Sprintf("*Quarantined submissions of %s:*\nSelect a submission below to review it\\.",
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name))
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, submission := range submissions {
+		label := fmt.Sprintf("#%d score %d, %s", submission.ID, submission.SpamScore, submission.CreatedAt.Format("2006-01-02 15:04"))
+		button := tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("quarantine_%d", submission.ID))
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
 	}
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
 	services.Bot.Send(msg)
 }
 
+func handleSetCaptchaCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/set_captcha\s+(\S+)\s+(none|optional|required)(?:\s+(\S+))?$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo set the captcha policy of a form run: \\/set\\_captcha FORM\\_NAME POLICY \\[PROVIDER\\]\n\n" +
+			"POLICY is none, optional or required\\. For example: \\/set\\_captcha contact required altcha"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	provider := strings.ToLower(matches[3])
+	providers := services.GetCaptchaProviders()
+	if provider != "" && !slices.Contains(providers, provider) {
+		msg.Text = "Unknown provider\\! Available providers: " + tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, strings.Join(providers, ", ")) + "\\."
+		services.Bot.Send(msg)
+		return
+	}
+	formToken.CaptchaPolicy = matches[2]
+	if provider != "" {
+		formToken.CaptchaProvider = provider
+	}
+	if err := formToken.Save(); err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+	} else {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ Captcha of %s is %s (%s).",
+			formToken.Name, services.GetCaptchaPolicy(formToken), services.GetCaptchaProvider(formToken)))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleSetRateLimitCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/set_rate_limit\s+(\S+)\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo limit how often a form accepts submissions run: \\/set\\_rate\\_limit FORM\\_NAME LIMIT\n\n" +
+			"For example: \\/set\\_rate\\_limit contact 10/1m\n" +
+			"Use 0 to turn the form's limit off, or default to go back to the default limit\\."
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	if matches[2] == "default" {
+		formToken.RateLimit = ""
+	} else {
+		limit, err := config.ParseRateLimit(matches[2])
+		if err != nil {
+			msg.Text = `Limit is not valid\! Write it as REQUESTS/INTERVAL, e\.g\. 10/1m or 100/1h\.`
+			services.Bot.Send(msg)
+			return
+		}
+		formToken.RateLimit = limit.String()
+	}
+	if err := formToken.Save(); err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+	} else if limit := services.GetFormRateLimit(formToken); !limit.Enabled() {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ %s has no rate limit of its own.", formToken.Name))
+	} else {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ %s accepts %d submissions every %s.",
+			formToken.Name, limit.Requests, limit.Interval))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleRateLimitStatsCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+	if !services.IsAdmin(user.TelegramUserID) {
+		msg.Text = `This command is for administrators only\.`
+		services.Bot.Send(msg)
+		return
+	}
+	msg.Text = "*Rate limiter*\n\n" + tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, services.GetRateLimitStatsText())
+	services.Bot.Send(msg)
+}
+
+func handleSetOriginPolicyCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/set_origin_policy\s+(\S+)\s+(accept|reject)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo accept or reject submissions without an origin run: \\/set\\_origin\\_policy FORM\\_NAME accept\\|reject\n\n" +
+			"Browsers always send an origin, so accept only forms that servers or apps post to\\. For example: \\/set\\_origin\\_policy contact reject"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	formToken.AcceptMissingOrigin = matches[2] == "accept"
+	if err := formToken.Save(); err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+	} else if formToken.AcceptMissingOrigin {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ %s accepts submissions without an Origin or Referer.", formToken.Name))
+	} else {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ %s rejects submissions without an Origin or Referer.", formToken.Name))
+	}
+	services.Bot.Send(msg)
+}
+
+func handleRotateApiKeyCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/rotate_api_key\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo create or rotate the API key of a form run: \\/rotate\\_api\\_key FORM\\_NAME"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	key, err := services.RotateFormApiKey(formToken)
+	if err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+		services.Bot.Send(msg)
+		return
+	}
+	msg.Text = fmt.Sprintf("✅ New API key of *%s*\\. Any previous key stops working now\\.\n\n`%s`\n\n"+
+		"Keep it on your server, it is shown only once\\. Post JSON to `%s` with the header `Authorization: Bearer KEY`\\.",
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, formToken.Name), key,
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, os.Getenv("BASE_URL")+"/api/"+formToken.Uuid.String()))
+	services.Bot.Send(msg)
+}
+
+func handleRevokeApiKeyCommand(update tgbotapi.Update) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+	msg.ParseMode = "MarkdownV2"
+	user := getVerifiedUser(update, &msg)
+	if user == nil {
+		return
+	}
+
+	commandRegex := regexp.MustCompile(`^/revoke_api_key\s+(\S+)$`)
+	matches := commandRegex.FindStringSubmatch(update.Message.Text)
+	if len(matches) == 0 {
+		msg.Text = "Invalid command format\\.\n\nTo revoke the API key of a form run: \\/revoke\\_api\\_key FORM\\_NAME"
+		services.Bot.Send(msg)
+		return
+	}
+	formToken, err := services.GetUserFormToken(user, matches[1])
+	if err != nil {
+		msg.Text = `Form not found\! Send \/tokens\_list to see your forms\.`
+		services.Bot.Send(msg)
+		return
+	}
+	if formToken.ApiKeyHash == "" {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("%s has no API key.", formToken.Name))
+	} else if err := services.RevokeFormApiKey(formToken); err != nil {
+		msg.Text = `Error occurred\! Please try again\.`
+	} else {
+		msg.Text = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("✅ The API key of %s is revoked.", formToken.Name))
+	}
+	services.Bot.Send(msg)
+}
+
 func handleCallbackQuery(update tgbotapi.Update) {
 	callbackData := update.CallbackQuery.Data
 	if strings.HasPrefix(callbackData, "token_") {
@@ -229,6 +1158,16 @@ func handleCallbackQuery(update tgbotapi.Update) {
 		handleDomainCallbackQuery(update, callbackData)
 	} else if strings.HasPrefix(callbackData, "delete_domain_") {
 		handleDeleteDomainCallbackQuery(update, callbackData)
+	} else if strings.HasPrefix(callbackData, "route_") {
+		handleRouteCallbackQuery(update, callbackData)
+	} else if strings.HasPrefix(callbackData, "delete_route_") {
+		handleDeleteRouteCallbackQuery(update, callbackData)
+	} else if strings.HasPrefix(callbackData, "quarantine_") {
+		handleQuarantineCallbackQuery(update, callbackData)
+	} else if strings.HasPrefix(callbackData, "release_submission_") {
+		handleReleaseSubmissionCallbackQuery(update, callbackData)
+	} else if strings.HasPrefix(callbackData, "discard_submission_") {
+		handleDiscardSubmissionCallbackQuery(update, callbackData)
 	}
 }
 
@@ -263,6 +1202,7 @@ func handleRevokeTokenCallbackQuery(update tgbotapi.Update, data string) {
 	if err != nil {
 		return
 	}
+	services.DeleteFormDomains(formToken)
 	editedMsg := tgbotapi.NewEditMessageText(chatID, messageID, "✅ Token revoked successfully!\n\nSend /tokens_list to see tokens.")
 	services.Bot.Send(editedMsg)
 }
@@ -271,15 +1211,16 @@ func handleDomainCallbackQuery(update tgbotapi.Update, data string) {
 	chatID := update.CallbackQuery.Message.Chat.ID
 	messageID := update.CallbackQuery.Message.MessageID
 	domainId, _ := strconv.ParseUint(strings.TrimPrefix(data, "domain_"), 10, 64)
-	domain, err := models.GetDomainById(domainId)
-	if err != nil {
+	domain := getCallbackDomain(update, domainId)
+	if domain == nil {
 		return
 	}
 	revokeButton := tgbotapi.NewInlineKeyboardButtonData("Delete", "delete_domain_"+fmt.Sprintf("%d", domain.ID))
 	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(revokeButton),
 	)
-	msgText := fmt.Sprintf("*%s*", strings.ReplaceAll(domain.Name, ".", "\\."))
+	msgText := fmt.Sprintf("*%s*\n\n%s", tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, domain.Name),
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, services.GetDomainVerificationText(domain)))
 	editedMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
 	editedMsg.ParseMode = "MarkdownV2"
 	editedMsg.ReplyMarkup = &inlineKeyboard
@@ -290,14 +1231,142 @@ func handleDeleteDomainCallbackQuery(update tgbotapi.Update, data string) {
 	chatID := update.CallbackQuery.Message.Chat.ID
 	messageID := update.CallbackQuery.Message.MessageID
 	domainId, _ := strconv.ParseUint(strings.TrimPrefix(data, "delete_domain_"), 10, 64)
+	domain := getCallbackDomain(update, domainId)
+	if domain == nil {
+		return
+	}
+	if err := domain.DeleteDomain(); err != nil {
+		return
+	}
+	editedMsg := tgbotapi.NewEditMessageText(chatID, messageID, "✅ Domain deleted successfully!\n\nSend /domains_list to see domains.")
+	services.Bot.Send(editedMsg)
+}
+
+func getCallbackDomain(update tgbotapi.Update, domainId uint64) *models.AllowedDomain {
 	domain, err := models.GetDomainById(domainId)
 	if err != nil {
+		return nil
+	}
+	user, err := models.GetByTelegramUserId(uint64(update.CallbackQuery.From.ID))
+	if err != nil || user.ID != domain.UserID {
+		return nil
+	}
+	return domain
+}
+
+func getCallbackRoute(update tgbotapi.Update, routeId uint64) *models.FormRoute {
+	route, err := models.GetFormRouteById(routeId)
+	if err != nil {
+		return nil
+	}
+	formToken, err := models.GetFormTokenByUuid(route.FormTokenUuid)
+	if err != nil {
+		return nil
+	}
+	user, err := models.GetByTelegramUserId(uint64(update.CallbackQuery.From.ID))
+	if err != nil || user.ID != formToken.UserID {
+		return nil
+	}
+	return route
+}
+
+func handleRouteCallbackQuery(update tgbotapi.Update, data string) {
+	chatID := update.CallbackQuery.Message.Chat.ID
+	messageID := update.CallbackQuery.Message.MessageID
+	routeId, _ := strconv.ParseUint(strings.TrimPrefix(data, "route_"), 10, 64)
+	route := getCallbackRoute(update, routeId)
+	if route == nil {
+		return
+	}
+	deleteButton := tgbotapi.NewInlineKeyboardButtonData("Delete", fmt.Sprintf("delete_route_%d", route.ID))
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(deleteButton),
+	)
+	msgText := fmt.Sprintf("*%s*\n`%s`",
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, route.Channel),
+		tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, route.Target))
+	editedMsg := tgbotapi.NewEditMessageText(chatID, messageID, msgText)
+	editedMsg.ParseMode = "MarkdownV2"
+	editedMsg.ReplyMarkup = &inlineKeyboard
+	services.Bot.Send(editedMsg)
+}
+
+func handleDeleteRouteCallbackQuery(update tgbotapi.Update, data string) {
+	chatID := update.CallbackQuery.Message.Chat.ID
+	messageID := update.CallbackQuery.Message.MessageID
+	routeId, _ := strconv.ParseUint(strings.TrimPrefix(data, "delete_route_"), 10, 64)
+	route := getCallbackRoute(update, routeId)
+	if route == nil {
+		return
+	}
+	if err := route.DeleteRoute(); err != nil {
 		return
 	}
-	err = domain.DeleteDomain()
+	editedMsg := tgbotapi.NewEditMessageText(chatID, messageID, "✅ Route deleted successfully!")
+	services.Bot.Send(editedMsg)
+}
+
+func getCallbackSubmission(update tgbotapi.Update, submissionId uint64) (*models.Submission, *models.FormToken) {
+	submission, err := models.GetSubmissionById(submissionId)
+	if err != nil || submission.Status != models.SubmissionStatusQuarantined {
+		return nil, nil
+	}
+	formToken, err := models.GetFormTokenByUuid(submission.FormTokenUuid)
 	if err != nil {
+		return nil, nil
+	}
+	user, err := models.GetByTelegramUserId(uint64(update.CallbackQuery.From.ID))
+	if err != nil || user.ID != formToken.UserID {
+		return nil, nil
+	}
+	return submission, formToken
+}
+
+func handleQuarantineCallbackQuery(update tgbotapi.Update, data string) {
+	chatID := update.CallbackQuery.Message.Chat.ID
+	messageID := update.CallbackQuery.Message.MessageID
+	submissionId, _ := strconv.ParseUint(strings.TrimPrefix(data, "quarantine_"), 10, 64)
+	submission, _ := getCallbackSubmission(update, submissionId)
+	if submission == nil {
 		return
 	}
-	editedMsg := tgbotapi.NewEditMessageText(chatID, messageID, "✅ Domain deleted successfully!\n\nSend /domains_list to see domains.")
+	releaseButton := tgbotapi.NewInlineKeyboardButtonData("Release", fmt.Sprintf("release_submission_%d", submission.ID))
+	discardButton := tgbotapi.NewInlineKeyboardButtonData("Discard", fmt.Sprintf("discard_submission_%d", submission.ID))
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(releaseButton, discardButton),
+	)
+	editedMsg := tgbotapi.NewEditMessageText(chatID, messageID, services.GetQuarantineText(submission))
+	editedMsg.ParseMode = "html"
+	editedMsg.ReplyMarkup = &inlineKeyboard
+	services.Bot.Send(editedMsg)
+}
+
+func handleReleaseSubmissionCallbackQuery(update tgbotapi.Update, data string) {
+	chatID := update.CallbackQuery.Message.Chat.ID
+	messageID := update.CallbackQuery.Message.MessageID
+	submissionId, _ := strconv.ParseUint(strings.TrimPrefix(data, "release_submission_"), 10, 64)
+	submission, formToken := getCallbackSubmission(update, submissionId)
+	if submission == nil {
+		return
+	}
+	if err := services.ReleaseSubmission(formToken, submission); err != nil {
+		return
+	}
+	editedMsg := tgbotapi.NewEditMessageText(chatID, messageID, "✅ Submission released and queued for delivery!")
+	services.Bot.Send(editedMsg)
+}
+
+func handleDiscardSubmissionCallbackQuery(update tgbotapi.Update, data string) {
+	chatID := update.CallbackQuery.Message.Chat.ID
+	messageID := update.CallbackQuery.Message.MessageID
+	submissionId, _ := strconv.ParseUint(strings.TrimPrefix(data, "discard_submission_"), 10, 64)
+	submission, _ := getCallbackSubmission(update, submissionId)
+	if submission == nil {
+		return
+	}
+	if err := services.DiscardSubmission(submission); err != nil {
+		return
+	}
+	editedMsg := tgbotapi.NewEditMessageText(chatID, messageID, "✅ Submission discarded successfully!")
 	services.Bot.Send(editedMsg)
 }
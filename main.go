@@ -4,6 +4,7 @@ import (
 	"core/config"
 	"core/models"
 	"core/routes"
+	"core/services"
 	"github.com/joho/godotenv"
 	"log"
 )
@@ -16,12 +17,26 @@ func main() {
 	config.DatabaseSetup()
 	migrate := config.GetDB().AutoMigrate(&models.User{},
 		&models.FormToken{},
-		&models.AllowedDomain{})
+		&models.AllowedDomain{},
+		&models.Submission{},
+		&models.Delivery{},
+		&models.FormRoute{},
+		&models.SubmissionFile{},
+		&models.SpamCounter{},
+		&models.ConsumedChallenge{},
+		&models.RateLimitWindow{})
 	if migrate != nil {
 		log.Fatalf("Error on migrations")
 	}
+	if err := services.MigrateLegacyRoutes(); err != nil {
+		log.Fatalf("Error on migrating form routes: %v", err)
+	}
+	if err := services.MigrateDomainVerificationTokens(); err != nil {
+		log.Fatalf("Error on migrating domain verification tokens: %v", err)
+	}
 
 	router := routes.SetupRoutes()
+	services.StartOutboxWorker()
 	if err := router.Run(":8030"); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
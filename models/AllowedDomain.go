@@ -2,14 +2,20 @@ package models
 
 import (
 	"core/config"
+	"github.com/google/uuid"
 	"time"
 )
 
+// AllowedDomain lets a domain submit to every form of a user, or only to the
+// form in FormTokenUuid when it is set.
 type AllowedDomain struct {
-	ID        uint64 `gorm:"autoIncrement;not null;primaryKey;unique"`
-	Name      string `gorm:"type:varchar(50)"`
-	UserID    uint64
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	ID                uint64 `gorm:"autoIncrement;not null;primaryKey;unique"`
+	Name              string `gorm:"type:varchar(255)"`
+	UserID            uint64
+	FormTokenUuid     *uuid.UUID `gorm:"type:uuid;index"`
+	VerificationToken string     `gorm:"type:varchar(64)"`
+	VerifiedAt        *time.Time
+	CreatedAt         time.Time `gorm:"default:CURRENT_TIMESTAMP"`
 }
 
 func (domain *AllowedDomain) Save() error {
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"core/config"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type ConsumedChallenge struct {
+	Signature string    `gorm:"type:varchar(128);not null;primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// ConsumeChallenge records a challenge signature and reports whether it was
+// recorded for the first time.
+func ConsumeChallenge(signature string, expiresAt time.Time) (bool, error) {
+	result := config.GetDB().Clauses(clause.OnConflict{DoNothing: true}).Create(&ConsumedChallenge{
+		Signature: signature,
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func DeleteExpiredChallenges() error {
+	return config.GetDB().Where("expires_at < ?", time.Now()).Delete(&ConsumedChallenge{}).Error
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"core/config"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed"
+
+	DeliveryChannelTelegram = "telegram"
+	DeliveryChannelEmail    = "email"
+	DeliveryChannelWebhook  = "webhook"
+	DeliveryChannelSlack    = "slack"
+)
+
+type Delivery struct {
+	ID               uint64 `gorm:"autoIncrement;not null;primaryKey;unique"`
+	SubmissionID     uint64 `gorm:"not null;index"`
+	Channel          string `gorm:"type:varchar(20);not null"`
+	Target           string `gorm:"type:varchar(2048);not null"`
+	Payload          string `gorm:"type:text;not null"`
+	SubmissionFileID *uint64
+	Status           string    `gorm:"type:varchar(20);not null;default:pending;index:idx_delivery_due,priority:1"`
+	Attempts         int       `gorm:"not null;default:0"`
+	NextAttemptAt    time.Time `gorm:"not null;index:idx_delivery_due,priority:2"`
+	LastError        string    `gorm:"type:text"`
+	SentAt           *time.Time
+	CreatedAt        time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	UpdatedAt        time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func (delivery *Delivery) Save() error {
+	return config.GetDB().Save(&delivery).Error
+}
+
+func (delivery *Delivery) MarkSent() error {
+	now := time.Now()
+	delivery.Status = DeliveryStatusSent
+	delivery.SentAt = &now
+	delivery.LastError = ""
+	return config.GetDB().Model(delivery).Updates(map[string]interface{}{
+		"status":     delivery.Status,
+		"sent_at":    delivery.SentAt,
+		"last_error": delivery.LastError,
+	}).Error
+}
+
+func (delivery *Delivery) MarkRetry(lastError string, nextAttemptAt time.Time) error {
+	delivery.LastError = lastError
+	delivery.NextAttemptAt = nextAttemptAt
+	return config.GetDB().Model(delivery).Updates(map[string]interface{}{
+		"last_error":      delivery.LastError,
+		"next_attempt_at": delivery.NextAttemptAt,
+	}).Error
+}
+
+// MarkThrottled schedules a retry without counting the attempt, for targets
+// that asked to slow down rather than failed.
+func (delivery *Delivery) MarkThrottled(lastError string, nextAttemptAt time.Time) error {
+	delivery.Attempts--
+	delivery.LastError = lastError
+	delivery.NextAttemptAt = nextAttemptAt
+	return config.GetDB().Model(delivery).Updates(map[string]interface{}{
+		"attempts":        gorm.Expr("attempts - 1"),
+		"last_error":      delivery.LastError,
+		"next_attempt_at": delivery.NextAttemptAt,
+	}).Error
+}
+
+func (delivery *Delivery) MarkFailed(lastError string) error {
+	delivery.Status = DeliveryStatusFailed
+	delivery.LastError = lastError
+	return config.GetDB().Model(delivery).Updates(map[string]interface{}{
+		"status":     delivery.Status,
+		"last_error": delivery.LastError,
+	}).Error
+}
+
+// ClaimDueDeliveries locks a batch of due deliveries and pushes their next attempt
+// forward by lease, so other workers skip them while they are being sent.
+func ClaimDueDeliveries(limit int, lease time.Duration) ([]Delivery, error) {
+	var deliveries []Delivery
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? and next_attempt_at <= ?", DeliveryStatusPending, now).
+			Order("next_attempt_at, id").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		ids := make([]uint64, 0, len(deliveries))
+		for i := range deliveries {
+			ids = append(ids, deliveries[i].ID)
+			deliveries[i].Attempts++
+		}
+		return tx.Model(&Delivery{}).Where("id in ?", ids).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(lease),
+		}).Error
+	})
+	return deliveries, err
+}
+
+func GetSubmissionDeliveries(submissionId uint64) []Delivery {
+	var deliveries []Delivery
+	config.GetDB().Where("submission_id = ?", submissionId).Order("id").Find(&deliveries)
+	return deliveries
+}
+
+func GetFormDeliveries(formTokenUuid uuid.UUID, channel string, limit int) []Delivery {
+	var deliveries []Delivery
+	config.GetDB().Joins("join submissions on submissions.id = deliveries.submission_id").
+		Where("submissions.form_token_uuid = ? and deliveries.channel = ?", formTokenUuid, channel).
+		Order("deliveries.id desc").
+		Limit(limit).
+		Find(&deliveries)
+	return deliveries
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"core/config"
+	"github.com/google/uuid"
+	"time"
+)
+
+// FormRouteTargetMaxLength is the width of FormRoute.Target and Delivery.Target.
+const FormRouteTargetMaxLength = 2048
+
+type FormRoute struct {
+	ID            uint64    `gorm:"autoIncrement;not null;primaryKey;unique"`
+	FormTokenUuid uuid.UUID `gorm:"type:uuid;not null;index"`
+	Channel       string    `gorm:"type:varchar(20);not null"`
+	Target        string    `gorm:"type:varchar(2048);not null"`
+	CreatedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func (route *FormRoute) Save() error {
+	return config.GetDB().Save(&route).Error
+}
+
+func (route *FormRoute) DeleteRoute() error {
+	return config.GetDB().Delete(&route).Error
+}
+
+func GetFormRouteById(id uint64) (*FormRoute, error) {
+	var route FormRoute
+	result := config.GetDB().Where("id = ?", id).First(&route)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &route, nil
+}
+
+func GetFormRoutes(formTokenUuid uuid.UUID) []FormRoute {
+	var routes []FormRoute
+	config.GetDB().Where("form_token_uuid = ?", formTokenUuid).Order("id").Find(&routes)
+	return routes
+}
@@ -6,12 +6,42 @@ import (
 	"time"
 )
 
+const (
+	CaptchaPolicyNone     = "none"
+	CaptchaPolicyOptional = "optional"
+	CaptchaPolicyRequired = "required"
+)
+
+const (
+	CaptchaProviderAltcha      = "altcha"
+	CaptchaProviderRecaptcha   = "recaptcha"
+	CaptchaProviderRecaptchaV3 = "recaptcha_v3"
+	CaptchaProviderHcaptcha    = "hcaptcha"
+	CaptchaProviderTurnstile   = "turnstile"
+)
+
 type FormToken struct {
-	Uuid      uuid.UUID `gorm:"type:uuid;not null;primaryKey;unique"`
-	Name      string    `gorm:"type:varchar(50)"`
-	UserID    uint64
-	ChatID    int64
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Uuid                uuid.UUID `gorm:"type:uuid;not null;primaryKey;unique"`
+	Name                string    `gorm:"type:varchar(50)"`
+	UserID              uint64
+	ChatID              int64
+	WebhookSecret       string `gorm:"type:varchar(100)"`
+	UploadMaxFiles      *int
+	UploadMaxFileSize   *int64
+	UploadMimeTypes     string `gorm:"type:varchar(255)"`
+	FieldOrder          string `gorm:"type:varchar(1000)"`
+	ValidationSchema    string `gorm:"type:text"`
+	HoneypotField       string `gorm:"type:varchar(50)"`
+	MinSubmitSeconds    *int
+	SpamThreshold       *int
+	SpamKeywords        string `gorm:"type:text"`
+	CaptchaPolicy       string `gorm:"type:varchar(20)"`
+	CaptchaProvider     string `gorm:"type:varchar(20)"`
+	RateLimit           string `gorm:"type:varchar(30)"`
+	AcceptMissingOrigin bool   `gorm:"not null;default:false"`
+	ApiKeyHash          string `gorm:"type:varchar(64)"`
+	ApiKeyCreatedAt     *time.Time
+	CreatedAt           time.Time `gorm:"default:CURRENT_TIMESTAMP"`
 }
 
 func (formToken *FormToken) Save() error {
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// RateLimitWindow counts the requests of a key in one fixed window of a
+// sliding window rate limit.
+type RateLimitWindow struct {
+	Key         string    `gorm:"type:varchar(255);not null;primaryKey"`
+	WindowStart int64     `gorm:"not null;primaryKey"`
+	Count       int       `gorm:"not null;default:0"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
+
+// TakeRateLimitWindow counts one request in the window starting at windowStart
+// unless the weighted count of the previous window plus the current one would
+// exceed limit. The check and the increment run as one statement, so replicas
+// sharing the database can't both take the last request.
+func TakeRateLimitWindow(db *gorm.DB, key string, windowStart int64, previousStart int64, previousWeight float64, limit int, expiresAt time.Time) (bool, error) {
+	var counts []int
+	err := db.Raw(`
+		with previous as (
+			select coalesce(max(count), 0) as count from rate_limit_windows where key = @key and window_start = @previous
+		)
+		insert into rate_limit_windows (key, window_start, count, expires_at)
+		select @key, @start, 1, @expires from previous where 1 + previous.count * cast(@weight as double precision) <= @limit
+		on conflict (key, window_start) do update set count = rate_limit_windows.count + 1
+		where rate_limit_windows.count + 1 + (select count from previous) * cast(@weight as double precision) <= @limit
+		returning count`,
+		map[string]interface{}{
+			"key":      key,
+			"start":    windowStart,
+			"previous": previousStart,
+			"weight":   previousWeight,
+			"limit":    limit,
+			"expires":  expiresAt,
+		},
+	).Scan(&counts).Error
+	if err != nil {
+		return false, err
+	}
+	return len(counts) == 1, nil
+}
+
+func CountRateLimitWindows(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Model(&RateLimitWindow{}).Where("expires_at >= ?", time.Now()).Count(&count).Error
+	return count, err
+}
+
+func DeleteExpiredRateLimitWindows(db *gorm.DB) error {
+	return db.Where("expires_at < ?", time.Now()).Delete(&RateLimitWindow{}).Error
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"core/config"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const (
+	SpamReasonHoneypot         = "honeypot"
+	SpamReasonTooFast          = "too_fast"
+	SpamReasonInvalidTimestamp = "invalid_timestamp"
+	SpamReasonMissingTimestamp = "missing_timestamp"
+	SpamReasonExpiredTimestamp = "expired_timestamp"
+	SpamReasonQuarantined      = "quarantined"
+)
+
+type SpamCounter struct {
+	FormTokenUuid uuid.UUID `gorm:"type:uuid;not null;primaryKey"`
+	Reason        string    `gorm:"type:varchar(30);not null;primaryKey"`
+	Count         int64     `gorm:"not null;default:0"`
+	UpdatedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func IncrementSpamCounter(formTokenUuid uuid.UUID, reason string) error {
+	counter := SpamCounter{FormTokenUuid: formTokenUuid, Reason: reason, Count: 1, UpdatedAt: time.Now()}
+	return config.GetDB().Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "form_token_uuid"}, {Name: "reason"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":      gorm.Expr("spam_counters.count + 1"),
+			"updated_at": counter.UpdatedAt,
+		}),
+	}).Create(&counter).Error
+}
+
+func GetSpamCounters(formTokenUuid uuid.UUID) []SpamCounter {
+	var counters []SpamCounter
+	config.GetDB().Where("form_token_uuid = ?", formTokenUuid).Order("reason").Find(&counters)
+	return counters
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"core/config"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+const (
+	SubmissionStatusPending   = "pending"
+	SubmissionStatusDelivered = "delivered"
+	SubmissionStatusFailed    = "failed"
+	// Quarantined submissions scored as spam and wait for the owner to release them
+	SubmissionStatusQuarantined = "quarantined"
+)
+
+type JSONData map[string]interface{}
+
+func (data JSONData) Value() (driver.Value, error) {
+	if data == nil {
+		return "{}", nil
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return string(value), nil
+}
+
+func (data *JSONData) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	case nil:
+		*data = JSONData{}
+		return nil
+	default:
+		return errors.New("unsupported type for JSONData")
+	}
+	return json.Unmarshal(bytes, data)
+}
+
+func (data JSONData) Values(key string) []string {
+	switch value := data[key].(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{value}
+	case []string:
+		return append([]string(nil), value...)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
+
+func (data JSONData) String(key string) string {
+	values := data.Values(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+type StringList []string
+
+func (list StringList) Value() (driver.Value, error) {
+	if list == nil {
+		return "[]", nil
+	}
+	value, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	return string(value), nil
+}
+
+func (list *StringList) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	case nil:
+		*list = StringList{}
+		return nil
+	default:
+		return errors.New("unsupported type for StringList")
+	}
+	return json.Unmarshal(bytes, list)
+}
+
+type Submission struct {
+	ID            uint64     `gorm:"autoIncrement;not null;primaryKey;unique"`
+	FormTokenUuid uuid.UUID  `gorm:"type:uuid;not null;index"`
+	Fields        JSONData   `gorm:"type:jsonb;not null"`
+	FieldOrder    StringList `gorm:"type:jsonb"`
+	Origin        string     `gorm:"type:varchar(255)"`
+	IP            string     `gorm:"type:varchar(45)"`
+	UserAgent     string     `gorm:"type:text"`
+	Status        string     `gorm:"type:varchar(20);not null;default:pending;index"`
+	SpamScore     int
+	SpamReasons   StringList       `gorm:"type:jsonb"`
+	PayloadHash   string           `gorm:"type:varchar(64);index"`
+	Files         []SubmissionFile `gorm:"foreignKey:SubmissionID"`
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	UpdatedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// OrderedKeys returns the field names in display order. Fields missing from
+// FieldOrder, e.g. on submissions stored before it existed, follow sorted by name.
+func (submission *Submission) OrderedKeys() []string {
+	keys := make([]string, 0, len(submission.Fields))
+	seen := map[string]bool{}
+	for _, key := range submission.FieldOrder {
+		if _, ok := submission.Fields[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+	var rest []string
+	for key := range submission.Fields {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+func (submission *Submission) Save() error {
+	return config.GetDB().Save(&submission).Error
+}
+
+func (submission *Submission) RefreshStatus() error {
+	status := SubmissionStatusDelivered
+	for _, delivery := range GetSubmissionDeliveries(submission.ID) {
+		if delivery.Status == DeliveryStatusPending {
+			status = SubmissionStatusPending
+			break
+		}
+		if delivery.Status == DeliveryStatusFailed {
+			status = SubmissionStatusFailed
+		}
+	}
+	if status == submission.Status {
+		return nil
+	}
+	return submission.UpdateStatus(status)
+}
+
+func (submission *Submission) UpdateStatus(status string) error {
+	submission.Status = status
+	updates := map[string]interface{}{"status": status}
+	if status == SubmissionStatusDelivered {
+		now := time.Now()
+		submission.DeliveredAt = &now
+		updates["delivered_at"] = submission.DeliveredAt
+	}
+	return config.GetDB().Model(submission).Updates(updates).Error
+}
+
+func GetSubmissionById(id uint64) (*Submission, error) {
+	var submission Submission
+	result := config.GetDB().Preload("Files").Where("id = ?", id).First(&submission)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &submission, nil
+}
+
+func GetQuarantinedSubmissions(formTokenUuid uuid.UUID, limit int) []Submission {
+	var submissions []Submission
+	config.GetDB().
+		Where("form_token_uuid = ? and status = ?", formTokenUuid, SubmissionStatusQuarantined).
+		Order("id desc").
+		Limit(limit).
+		Find(&submissions)
+	return submissions
+}
+
+func CountSubmissionsByPayloadHash(formTokenUuid uuid.UUID, payloadHash string, since time.Time) int64 {
+	var count int64
+	config.GetDB().Model(&Submission{}).
+		Where("form_token_uuid = ? and payload_hash = ? and created_at >= ?", formTokenUuid, payloadHash, since).
+		Count(&count)
+	return count
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"core/config"
+	"time"
+)
+
+type SubmissionFile struct {
+	ID           uint64    `gorm:"autoIncrement;not null;primaryKey;unique"`
+	SubmissionID uint64    `gorm:"not null;index"`
+	FieldName    string    `gorm:"type:varchar(255)"`
+	FileName     string    `gorm:"type:varchar(255)"`
+	MimeType     string    `gorm:"type:varchar(255)"`
+	Size         int64     `gorm:"not null"`
+	StorageKey   string    `gorm:"type:varchar(255);not null"`
+	CreatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func GetSubmissionFileById(id uint64) (*SubmissionFile, error) {
+	var file SubmissionFile
+	result := config.GetDB().Where("id = ?", id).First(&file)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &file, nil
+}
+
+func GetSubmissionFiles(submissionId uint64) []SubmissionFile {
+	var files []SubmissionFile
+	config.GetDB().Where("submission_id = ?", submissionId).Order("id").Find(&files)
+	return files
+}
@@ -5,33 +5,40 @@ import (
 	"core/controllers/api"
 	"core/controllers/telegram"
 	"core/services"
-	"os"
-	"time"
+	"log"
 
-	"github.com/dpapathanasiou/go-recaptcha"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes() *gin.Engine {
 	router := gin.Default()
+	if err := config.ConfigureTrustedProxies(router); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
 
-	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{"*"}
-	router.Use(cors.New(corsConfig))
-
-	router.Use(config.RateLimitMiddleware(time.Minute, 30))
+	services.InitRateLimits()
 
 	router.LoadHTMLGlob("views/*.html")
 	router.Static("/assets", "views/assets")
 
-	recaptcha.Init(os.Getenv("RECAPTCHA_SECRET_KEY"))
+	services.InitCaptcha()
+	// The submit route limit is checked by the handler, after the CORS headers are set
 	router.POST("/:data", api.CreateFormData)
+	// Form endpoints answer CORS for their allowed domains only
+	router.OPTIONS("/:data", config.RouteRateLimitMiddleware("preflight", services.RATE_LIMIT_SUBMIT), api.FormPreflight)
 
+	router.POST("/api/:data", config.RouteRateLimitMiddleware("api", services.RATE_LIMIT_API), api.CreateApiSubmission)
+
+	services.InitFileStore()
 	services.InitTelegram()
-	router.POST("/"+services.Token, telegram.TelegramWebhookHandler)
+	router.POST("/"+services.Token, config.RouteRateLimitMiddleware("telegram", services.RATE_LIMIT_TELEGRAM), telegram.TelegramWebhookHandler)
 
-	router.GET("/captcha", services.AltchaHandler)
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = []string{"*"}
+	captcha := router.Group("/", cors.New(corsConfig), config.RouteRateLimitMiddleware("captcha", services.RATE_LIMIT_CAPTCHA))
+	captcha.GET("/captcha", services.AltchaHandler)
+	captcha.GET("/timestamp", services.TimestampHandler)
 
 	return router
 }
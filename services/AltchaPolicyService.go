@@ -0,0 +1,137 @@
+package services
+
+import (
+	"core/utils"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	SIGNAL_SUBMISSION = 1.0
+	SIGNAL_RATE_LIMIT = 2.0
+	SIGNAL_SPAM       = 3.0
+)
+
+// AltchaPolicy picks the difficulty and expiry of ALTCHA challenges. Every
+// submission, rate limit hit and blocked spam adds to a score per IP and per
+// form that decays with a half-life of SignalHalfLife. Clean clients get
+// MinNumber, and every BurstThreshold points double BaseNumber up to MaxNumber
+// while the expiry shrinks towards MinExpires.
+type AltchaPolicy struct {
+	MinNumber      int64
+	BaseNumber     int64
+	MaxNumber      int64
+	Expires        time.Duration
+	MinExpires     time.Duration
+	SignalHalfLife time.Duration
+	BurstThreshold float64
+
+	mu        sync.Mutex
+	signals   map[string]*abuseSignal
+	lastSweep time.Time
+}
+
+type abuseSignal struct {
+	score     float64
+	updatedAt time.Time
+}
+
+var Altcha = NewAltchaPolicy()
+
+func NewAltchaPolicy() *AltchaPolicy {
+	return &AltchaPolicy{
+		MinNumber:      50_000,
+		BaseNumber:     100_000,
+		MaxNumber:      2_000_000,
+		Expires:        2 * time.Minute,
+		MinExpires:     time.Minute,
+		SignalHalfLife: 10 * time.Minute,
+		BurstThreshold: 5,
+		signals:        map[string]*abuseSignal{},
+		lastSweep:      time.Now(),
+	}
+}
+
+func (policy *AltchaPolicy) LoadEnv() {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	policy.MinNumber = utils.GetEnvInt64("ALTCHA_MIN_NUMBER", policy.MinNumber)
+	policy.BaseNumber = utils.GetEnvInt64("ALTCHA_BASE_NUMBER", policy.BaseNumber)
+	policy.MaxNumber = utils.GetEnvInt64("ALTCHA_MAX_NUMBER", policy.MaxNumber)
+	policy.Expires = utils.GetEnvDuration("ALTCHA_EXPIRES", policy.Expires)
+	policy.MinExpires = utils.GetEnvDuration("ALTCHA_MIN_EXPIRES", policy.MinExpires)
+	policy.SignalHalfLife = utils.GetEnvDuration("ALTCHA_SIGNAL_HALF_LIFE", policy.SignalHalfLife)
+	policy.BurstThreshold = utils.GetEnvFloat("ALTCHA_BURST_THRESHOLD", policy.BurstThreshold)
+}
+
+// Record adds weight to the scores of an IP and a form. Either may be empty.
+func (policy *AltchaPolicy) Record(ip string, formUuid string, weight float64) {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	now := time.Now()
+	policy.sweep(now)
+	for _, key := range signalKeys(ip, formUuid) {
+		signal, ok := policy.signals[key]
+		if !ok {
+			signal = &abuseSignal{updatedAt: now}
+			policy.signals[key] = signal
+		}
+		signal.score = policy.decay(signal, now) + weight
+		signal.updatedAt = now
+	}
+}
+
+// Difficulty returns the MaxNumber and expiry of a challenge for an IP and form.
+func (policy *AltchaPolicy) Difficulty(ip string, formUuid string) (int64, time.Duration) {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	now := time.Now()
+	score := 0.0
+	for _, key := range signalKeys(ip, formUuid) {
+		if signal, ok := policy.signals[key]; ok {
+			score += policy.decay(signal, now)
+		}
+	}
+	if score < 0.5 {
+		return policy.MinNumber, policy.Expires
+	}
+	level := 0
+	if policy.BurstThreshold > 0 {
+		level = min(int(score/policy.BurstThreshold), 20)
+	}
+	maxNumber := min(policy.BaseNumber<<level, policy.MaxNumber)
+	expires := max(policy.Expires>>level, policy.MinExpires)
+	return maxNumber, expires
+}
+
+func (policy *AltchaPolicy) decay(signal *abuseSignal, now time.Time) float64 {
+	if policy.SignalHalfLife <= 0 {
+		return signal.score
+	}
+	halfLives := now.Sub(signal.updatedAt).Seconds() / policy.SignalHalfLife.Seconds()
+	return signal.score * math.Pow(0.5, halfLives)
+}
+
+func (policy *AltchaPolicy) sweep(now time.Time) {
+	if now.Sub(policy.lastSweep) < policy.SignalHalfLife {
+		return
+	}
+	for key, signal := range policy.signals {
+		if policy.decay(signal, now) < 0.01 {
+			delete(policy.signals, key)
+		}
+	}
+	policy.lastSweep = now
+}
+
+func signalKeys(ip string, formUuid string) []string {
+	var keys []string
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	if formUuid != "" {
+		keys = append(keys, "form:"+formUuid)
+	}
+	return keys
+}
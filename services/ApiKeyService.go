@@ -0,0 +1,60 @@
+package services
+
+import (
+	"core/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+const API_KEY_PREFIX = "fmk_"
+
+func GenerateApiKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return API_KEY_PREFIX + hex.EncodeToString(key), nil
+}
+
+// Only a hash of the key is stored, so the key is shown once when it is created.
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// RotateFormApiKey replaces the form's API key, invalidating the old one
+// immediately, and returns the new key.
+func RotateFormApiKey(formToken *models.FormToken) (string, error) {
+	key, err := GenerateApiKey()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	formToken.ApiKeyHash = hashApiKey(key)
+	formToken.ApiKeyCreatedAt = &now
+	if err := formToken.Save(); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func RevokeFormApiKey(formToken *models.FormToken) error {
+	formToken.ApiKeyHash = ""
+	formToken.ApiKeyCreatedAt = nil
+	return formToken.Save()
+}
+
+// AuthenticateApiKey checks an "Authorization: Bearer KEY" header against the
+// form's API key.
+func AuthenticateApiKey(formToken *models.FormToken, authorization string) bool {
+	scheme, key, found := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || formToken.ApiKeyHash == "" {
+		return false
+	}
+	hash := hashApiKey(strings.TrimSpace(key))
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(formToken.ApiKeyHash)) == 1
+}
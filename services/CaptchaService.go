@@ -1,22 +1,127 @@
 package services
 
 import (
+	"core/models"
+	"core/utils"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/altcha-org/altcha-lib-go"
 )
 
-var altchaHMACKey = os.Getenv("ALTCHA_HMAC_KEY")
+var (
+	altchaHMACKey = os.Getenv("ALTCHA_HMAC_KEY")
+	altchaReplays ReplayStore
+)
+
+var (
+	CAPTCHA_DEFAULT_POLICY = models.CaptchaPolicyOptional
+	RECAPTCHA_VERIFY_URL   = "https://www.google.com/recaptcha/api/siteverify"
+	RECAPTCHA_MIN_SCORE    = 0.5
+	HCAPTCHA_VERIFY_URL    = "https://api.hcaptcha.com/siteverify"
+	TURNSTILE_VERIFY_URL   = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+	CAPTCHA_VERIFY_TIMEOUT = 10 * time.Second
+)
+
+var (
+	errCaptchaNotConfigured  = errors.New("captcha provider is not configured")
+	errCaptchaScoreTooLow    = errors.New("captcha score is below the threshold")
+	errCaptchaSolutionFailed = errors.New("captcha solution was rejected")
+	errCaptchaReplayed       = errors.New("captcha solution was already used")
+)
+
+// CaptchaVerifier checks the solution a visitor posted in Field.
+type CaptchaVerifier interface {
+	Field() string
+	Verify(solution string, remoteIP string) error
+}
+
+var captchaVerifiers = map[string]CaptchaVerifier{}
+
+func RegisterCaptchaVerifier(provider string, verifier CaptchaVerifier) {
+	captchaVerifiers[provider] = verifier
+}
 
+func GetCaptchaVerifier(provider string) (CaptchaVerifier, bool) {
+	verifier, ok := captchaVerifiers[provider]
+	return verifier, ok
+}
+
+func GetCaptchaProviders() []string {
+	providers := make([]string, 0, len(captchaVerifiers))
+	for provider := range captchaVerifiers {
+		providers = append(providers, provider)
+	}
+	slices.Sort(providers)
+	return providers
+}
+
+// InitCaptcha registers the verifiers with the keys and endpoints from the
+// environment. It runs after the .env file is loaded.
+func InitCaptcha() {
+	altchaHMACKey = os.Getenv("ALTCHA_HMAC_KEY")
+	if timestampKey() == "" {
+		log.Println("Spam => Neither FORM_TIMESTAMP_KEY nor ALTCHA_HMAC_KEY is set, render timestamps are disabled")
+	}
+	Altcha.LoadEnv()
+	altchaReplays = NewReplayStore(utils.GetEnvString("ALTCHA_REPLAY_STORE", STORE_MEMORY))
+	client := &http.Client{Timeout: CAPTCHA_VERIFY_TIMEOUT}
+	recaptchaSecret := os.Getenv("RECAPTCHA_SECRET_KEY")
+	recaptchaEndpoint := utils.GetEnvString("RECAPTCHA_VERIFY_URL", RECAPTCHA_VERIFY_URL)
+
+	RegisterCaptchaVerifier(models.CaptchaProviderAltcha, AltchaVerifier{HMACKey: altchaHMACKey, Replays: altchaReplays})
+	RegisterCaptchaVerifier(models.CaptchaProviderRecaptcha, &SiteVerifyVerifier{
+		Endpoint:      recaptchaEndpoint,
+		Secret:        recaptchaSecret,
+		ResponseField: "g-recaptcha-response",
+		Client:        client,
+	})
+	RegisterCaptchaVerifier(models.CaptchaProviderRecaptchaV3, &SiteVerifyVerifier{
+		Endpoint:      recaptchaEndpoint,
+		Secret:        recaptchaSecret,
+		ResponseField: "g-recaptcha-response",
+		MinScore:      utils.GetEnvFloat("RECAPTCHA_MIN_SCORE", RECAPTCHA_MIN_SCORE),
+		Client:        client,
+	})
+	RegisterCaptchaVerifier(models.CaptchaProviderHcaptcha, &SiteVerifyVerifier{
+		Endpoint:      utils.GetEnvString("HCAPTCHA_VERIFY_URL", HCAPTCHA_VERIFY_URL),
+		Secret:        os.Getenv("HCAPTCHA_SECRET_KEY"),
+		ResponseField: "h-captcha-response",
+		Client:        client,
+	})
+	RegisterCaptchaVerifier(models.CaptchaProviderTurnstile, &SiteVerifyVerifier{
+		Endpoint:      utils.GetEnvString("TURNSTILE_VERIFY_URL", TURNSTILE_VERIFY_URL),
+		Secret:        os.Getenv("TURNSTILE_SECRET_KEY"),
+		ResponseField: "cf-turnstile-response",
+		Client:        client,
+	})
+}
+
+// AltchaHandler issues a challenge whose difficulty follows Altcha's policy for
+// the client IP and, when the form query parameter is set, the form.
 func AltchaHandler(c *gin.Context) {
-	expires := time.Now().Add(2 * time.Minute)
+	formUuid := utils.GetUUIDFromString(c.Query("form"))
+	formKey := ""
+	if formUuid != uuid.Nil {
+		formKey = formUuid.String()
+	}
+	maxNumber, expiry := Altcha.Difficulty(c.ClientIP(), formKey)
+	expires := time.Now().Add(expiry)
 	challenge, err := altcha.CreateChallenge(altcha.ChallengeOptions{
 		HMACKey:   altchaHMACKey,
-		MaxNumber: 100_000,
+		MaxNumber: maxNumber,
 		Expires:   &expires,
 	})
 	if err != nil {
@@ -27,11 +132,176 @@ func AltchaHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, challenge)
 }
 
-func IsCaptchaValid(altchaParam string) bool {
-	verified, err := altcha.VerifySolution(altchaParam, altchaHMACKey, true)
-	if err != nil || !verified {
-		return false
+// AltchaVerifier checks ALTCHA payloads. With a Replays store every solved
+// challenge is accepted only once.
+type AltchaVerifier struct {
+	HMACKey string
+	Replays ReplayStore
+}
+
+func (AltchaVerifier) Field() string {
+	return "altcha"
+}
+
+func (verifier AltchaVerifier) Verify(solution string, remoteIP string) error {
+	if verifier.HMACKey == "" {
+		return errCaptchaNotConfigured
+	}
+	decoded, err := base64.StdEncoding.DecodeString(solution)
+	if err != nil {
+		return errCaptchaSolutionFailed
+	}
+	var payload altcha.Payload
+	if err := json.Unmarshal(decoded, &payload); err != nil {
+		return errCaptchaSolutionFailed
+	}
+	// Challenges without an expiry could be replayed forever
+	expires, err := strconv.ParseInt(altcha.ExtractParams(payload).Get("expires"), 10, 64)
+	if err != nil {
+		return errCaptchaSolutionFailed
+	}
+	verified, err := altcha.VerifySolution(payload, verifier.HMACKey, true)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return errCaptchaSolutionFailed
+	}
+	if verifier.Replays == nil {
+		return nil
 	}
+	fresh, err := verifier.Replays.Consume(payload.Signature, time.Unix(expires, 0))
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return errCaptchaReplayed
+	}
+	return nil
+}
+
+// SiteVerifyVerifier checks tokens against a siteverify endpoint. reCAPTCHA,
+// hCaptcha and Turnstile share the same request and response format. A
+// MinScore above zero also checks the score reCAPTCHA v3 returns.
+type SiteVerifyVerifier struct {
+	Endpoint      string
+	Secret        string
+	ResponseField string
+	MinScore      float64
+	Client        *http.Client
+}
+
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	Score      *float64 `json:"score"`
+	Action     string   `json:"action"`
+	Hostname   string   `json:"hostname"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+func (verifier *SiteVerifyVerifier) Field() string {
+	return verifier.ResponseField
+}
+
+func (verifier *SiteVerifyVerifier) Verify(solution string, remoteIP string) error {
+	if verifier.Secret == "" || verifier.Endpoint == "" {
+		return errCaptchaNotConfigured
+	}
+	form := url.Values{"secret": {verifier.Secret}, "response": {solution}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	client := verifier.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.PostForm(verifier.Endpoint, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha verification returned status %d", resp.StatusCode)
+	}
+	var result siteVerifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		if len(result.ErrorCodes) > 0 {
+			return fmt.Errorf("%w: %s", errCaptchaSolutionFailed, strings.Join(result.ErrorCodes, ", "))
+		}
+		return errCaptchaSolutionFailed
+	}
+	if verifier.MinScore > 0 && (result.Score == nil || *result.Score < verifier.MinScore) {
+		return errCaptchaScoreTooLow
+	}
+	return nil
+}
+
+func GetCaptchaPolicy(formToken *models.FormToken) string {
+	if formToken.CaptchaPolicy != "" {
+		return formToken.CaptchaPolicy
+	}
+	return utils.GetEnvString("CAPTCHA_DEFAULT_POLICY", CAPTCHA_DEFAULT_POLICY)
+}
+
+func GetCaptchaProvider(formToken *models.FormToken) string {
+	if formToken.CaptchaProvider != "" {
+		return formToken.CaptchaProvider
+	}
+	return models.CaptchaProviderAltcha
+}
 
-	return true
+type CaptchaError struct {
+	Message string
+}
+
+func (e *CaptchaError) Error() string {
+	return e.Message
+}
+
+// VerifyFormCaptcha enforces the form's captcha policy with the form's
+// provider. Forms that require a captcha reject submissions without a solution
+// instead of trusting the client to send one. Solution fields of every provider
+// are removed from the data.
+func VerifyFormCaptcha(formToken *models.FormToken, fields models.JSONData, remoteIP string) error {
+	policy := GetCaptchaPolicy(formToken)
+	verifier, ok := GetCaptchaVerifier(GetCaptchaProvider(formToken))
+	var exists bool
+	var solution string
+	if ok {
+		_, exists = fields[verifier.Field()]
+		solution = strings.TrimSpace(fields.String(verifier.Field()))
+	}
+	for _, registered := range captchaVerifiers {
+		delete(fields, registered.Field())
+	}
+
+	switch policy {
+	case models.CaptchaPolicyNone:
+		return nil
+	case models.CaptchaPolicyRequired:
+		if !ok {
+			log.Printf("Captcha => Form %s requires unknown provider %s", formToken.Uuid, GetCaptchaProvider(formToken))
+			return &CaptchaError{Message: "Captcha is not valid."}
+		}
+		if solution == "" {
+			return &CaptchaError{Message: "Captcha is required."}
+		}
+	default:
+		if !exists {
+			return nil
+		}
+		if solution == "" {
+			return &CaptchaError{Message: "Error occurred while submitting a form."}
+		}
+	}
+	if err := verifier.Verify(solution, remoteIP); err != nil {
+		if !errors.Is(err, errCaptchaSolutionFailed) && !errors.Is(err, errCaptchaScoreTooLow) && !errors.Is(err, errCaptchaReplayed) {
+			log.Printf("Captcha => Error verifying %s solution for form %s: %v", GetCaptchaProvider(formToken), formToken.Uuid, err)
+		}
+		return &CaptchaError{Message: "Captcha is not valid."}
+	}
+	return nil
 }
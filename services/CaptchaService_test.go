@@ -0,0 +1,165 @@
+package services
+
+import (
+	"core/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/altcha-org/altcha-lib-go"
+)
+
+// newSiteVerifyServer fakes a siteverify endpoint that answers with response
+// and records the last form it received.
+func newSiteVerifyServer(t *testing.T, status int, response string) (*httptest.Server, *http.Request) {
+	t.Helper()
+	received := &http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		*received = *r
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestSiteVerifyVerifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		minScore float64
+		wantErr  error
+	}{
+		{"success", http.StatusOK, `{"success": true}`, 0, nil},
+		{"rejected", http.StatusOK, `{"success": false, "error-codes": ["invalid-input-response"]}`, 0, errCaptchaSolutionFailed},
+		{"v3 score above threshold", http.StatusOK, `{"success": true, "score": 0.9}`, 0.5, nil},
+		{"v3 score below threshold", http.StatusOK, `{"success": true, "score": 0.1}`, 0.5, errCaptchaScoreTooLow},
+		{"v3 score missing", http.StatusOK, `{"success": true}`, 0.5, errCaptchaScoreTooLow},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, received := newSiteVerifyServer(t, test.status, test.response)
+			verifier := &SiteVerifyVerifier{
+				Endpoint:      server.URL,
+				Secret:        "secret",
+				ResponseField: "g-recaptcha-response",
+				MinScore:      test.minScore,
+				Client:        server.Client(),
+			}
+			err := verifier.Verify("token", "203.0.113.7")
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Verify() = %v, want %v", err, test.wantErr)
+			}
+			if received.PostForm.Get("secret") != "secret" || received.PostForm.Get("response") != "token" ||
+				received.PostForm.Get("remoteip") != "203.0.113.7" {
+				t.Errorf("unexpected siteverify request %v", received.PostForm)
+			}
+		})
+	}
+}
+
+func TestSiteVerifyVerifierErrors(t *testing.T) {
+	server, _ := newSiteVerifyServer(t, http.StatusInternalServerError, "")
+	verifier := &SiteVerifyVerifier{Endpoint: server.URL, Secret: "secret", Client: server.Client()}
+	if err := verifier.Verify("token", ""); err == nil || errors.Is(err, errCaptchaSolutionFailed) {
+		t.Errorf("expected a server error, got %v", err)
+	}
+
+	unconfigured := &SiteVerifyVerifier{Endpoint: server.URL}
+	if err := unconfigured.Verify("token", ""); !errors.Is(err, errCaptchaNotConfigured) {
+		t.Errorf("expected errCaptchaNotConfigured, got %v", err)
+	}
+}
+
+func newAltchaSolution(t *testing.T, hmacKey string, expires time.Time) string {
+	t.Helper()
+	challenge, err := altcha.CreateChallenge(altcha.ChallengeOptions{
+		HMACKey:   hmacKey,
+		MaxNumber: 10,
+		Number:    7,
+		Expires:   &expires,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := json.Marshal(altcha.Payload{
+		Algorithm: challenge.Algorithm,
+		Challenge: challenge.Challenge,
+		Number:    7,
+		Salt:      challenge.Salt,
+		Signature: challenge.Signature,
+	})
+	return base64.StdEncoding.EncodeToString(payload)
+}
+
+func TestAltchaVerifierRejectsReplays(t *testing.T) {
+	verifier := AltchaVerifier{HMACKey: "key", Replays: NewMemoryReplayStore()}
+	solution := newAltchaSolution(t, "key", time.Now().Add(time.Minute))
+	if err := verifier.Verify(solution, ""); err != nil {
+		t.Fatalf("first use should pass, got %v", err)
+	}
+	if err := verifier.Verify(solution, ""); !errors.Is(err, errCaptchaReplayed) {
+		t.Fatalf("second use should be a replay, got %v", err)
+	}
+}
+
+func TestAltchaVerifierRejectsInvalidSolutions(t *testing.T) {
+	verifier := AltchaVerifier{HMACKey: "key", Replays: NewMemoryReplayStore()}
+	tests := map[string]string{
+		"other key":     newAltchaSolution(t, "other", time.Now().Add(time.Minute)),
+		"not base64":    "%%%",
+		"not a payload": base64.StdEncoding.EncodeToString([]byte("[]")),
+	}
+	for name, solution := range tests {
+		if err := verifier.Verify(solution, ""); err == nil {
+			t.Errorf("%s: expected the solution to be rejected", name)
+		}
+	}
+}
+
+func TestVerifyFormCaptchaPolicies(t *testing.T) {
+	server, _ := newSiteVerifyServer(t, http.StatusOK, `{"success": true}`)
+	defaultVerifier, registered := captchaVerifiers[models.CaptchaProviderTurnstile]
+	t.Cleanup(func() {
+		if registered {
+			captchaVerifiers[models.CaptchaProviderTurnstile] = defaultVerifier
+		} else {
+			delete(captchaVerifiers, models.CaptchaProviderTurnstile)
+		}
+	})
+	RegisterCaptchaVerifier(models.CaptchaProviderTurnstile, &SiteVerifyVerifier{
+		Endpoint:      server.URL,
+		Secret:        "secret",
+		ResponseField: "cf-turnstile-response",
+		Client:        server.Client(),
+	})
+	formToken := &models.FormToken{CaptchaProvider: models.CaptchaProviderTurnstile}
+
+	tests := []struct {
+		policy  string
+		fields  models.JSONData
+		wantErr bool
+	}{
+		{models.CaptchaPolicyNone, models.JSONData{}, false},
+		{models.CaptchaPolicyOptional, models.JSONData{}, false},
+		{models.CaptchaPolicyOptional, models.JSONData{"cf-turnstile-response": "token"}, false},
+		{models.CaptchaPolicyRequired, models.JSONData{}, true},
+		{models.CaptchaPolicyRequired, models.JSONData{"cf-turnstile-response": "token"}, false},
+	}
+	for _, test := range tests {
+		formToken.CaptchaPolicy = test.policy
+		err := VerifyFormCaptcha(formToken, test.fields, "")
+		if (err != nil) != test.wantErr {
+			t.Errorf("policy %s with %v: got %v", test.policy, test.fields, err)
+		}
+		if _, exists := test.fields["cf-turnstile-response"]; exists {
+			t.Errorf("policy %s: the solution field should be removed", test.policy)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/idna"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DomainPattern is an allowed domain as the owner wrote it. Host may start with
+// "*." to match every subdomain. An empty Scheme or Port matches any.
+type DomainPattern struct {
+	Scheme   string
+	Host     string
+	Port     string
+	Wildcard bool
+}
+
+// ParseDomainPattern reads patterns such as example.com, *.example.com,
+// https://example.com or localhost:3000. Hosts are lowercased and IDNs are
+// converted to punycode.
+func ParseDomainPattern(value string) (DomainPattern, error) {
+	var pattern DomainPattern
+	value = strings.TrimSpace(value)
+	if scheme, rest, found := strings.Cut(value, "://"); found {
+		pattern.Scheme = strings.ToLower(scheme)
+		if pattern.Scheme != "http" && pattern.Scheme != "https" {
+			return pattern, fmt.Errorf("unsupported scheme %q", scheme)
+		}
+		value = rest
+	}
+	value = strings.TrimSuffix(value, "/")
+	if host, port, err := net.SplitHostPort(value); err == nil {
+		value = host
+		pattern.Port = port
+	}
+	if strings.HasPrefix(value, "*.") {
+		pattern.Wildcard = true
+		value = strings.TrimPrefix(value, "*.")
+	}
+	host, err := NormalizeHost(value)
+	if err != nil {
+		return pattern, err
+	}
+	pattern.Host = host
+	return pattern, nil
+}
+
+// NormalizeHost lowercases a host, drops a trailing dot and converts IDNs to
+// their ASCII form so bücher.de and xn--bcher-kva.de compare equal.
+func NormalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if host == "" {
+		return "", errors.New("empty host")
+	}
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	return idna.Lookup.ToASCII(host)
+}
+
+func (pattern DomainPattern) String() string {
+	value := pattern.Host
+	if pattern.Wildcard {
+		value = "*." + value
+	}
+	if pattern.Port != "" {
+		value = net.JoinHostPort(value, pattern.Port)
+	}
+	if pattern.Scheme != "" {
+		value = pattern.Scheme + "://" + value
+	}
+	return value
+}
+
+// Matches reports whether an origin URL is allowed by the pattern. Ports are
+// compared after filling in the scheme's default port.
+func (pattern DomainPattern) Matches(origin *url.URL) bool {
+	if origin == nil {
+		return false
+	}
+	scheme := strings.ToLower(origin.Scheme)
+	if pattern.Scheme != "" && pattern.Scheme != scheme {
+		return false
+	}
+	if pattern.Port != "" && pattern.Port != effectivePort(scheme, origin.Port()) {
+		return false
+	}
+	host, err := NormalizeHost(origin.Hostname())
+	if err != nil {
+		return false
+	}
+	if pattern.Wildcard {
+		return strings.HasSuffix(host, "."+pattern.Host)
+	}
+	return host == pattern.Host
+}
+
+func effectivePort(scheme string, port string) string {
+	if port != "" {
+		return port
+	}
+	switch scheme {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// MatchesAllowedDomain reports whether any of the allowed domains matches origin.
+func MatchesAllowedDomain(domains []string, origin *url.URL) bool {
+	for _, domain := range domains {
+		pattern, err := ParseDomainPattern(domain)
+		if err == nil && pattern.Matches(origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseDomainPattern(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected DomainPattern
+		text     string
+	}{
+		{"Example.COM", DomainPattern{Host: "example.com"}, "example.com"},
+		{"example.com.", DomainPattern{Host: "example.com"}, "example.com"},
+		{"*.example.com", DomainPattern{Host: "example.com", Wildcard: true}, "*.example.com"},
+		{"https://example.com/", DomainPattern{Scheme: "https", Host: "example.com"}, "https://example.com"},
+		{"localhost:3000", DomainPattern{Host: "localhost", Port: "3000"}, "localhost:3000"},
+		{"http://*.example.com:8080", DomainPattern{Scheme: "http", Host: "example.com", Port: "8080", Wildcard: true}, "http://*.example.com:8080"},
+		{"bücher.de", DomainPattern{Host: "xn--bcher-kva.de"}, "xn--bcher-kva.de"},
+	}
+	for _, test := range tests {
+		pattern, err := ParseDomainPattern(test.value)
+		if err != nil {
+			t.Errorf("ParseDomainPattern(%q) failed: %v", test.value, err)
+			continue
+		}
+		if pattern != test.expected {
+			t.Errorf("ParseDomainPattern(%q) = %+v, want %+v", test.value, pattern, test.expected)
+		}
+		if pattern.String() != test.text {
+			t.Errorf("ParseDomainPattern(%q).String() = %q, want %q", test.value, pattern.String(), test.text)
+		}
+	}
+	for _, value := range []string{"", "ftp://example.com", "*."} {
+		if _, err := ParseDomainPattern(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestDomainPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		origin  string
+		matches bool
+	}{
+		{"example.com", "https://example.com", true},
+		{"example.com", "http://example.com:8080", true},
+		{"example.com", "https://www.example.com", false},
+		{"example.com", "https://example.com.evil.net", false},
+		{"*.example.com", "https://www.example.com", true},
+		{"*.example.com", "https://a.b.example.com", true},
+		{"*.example.com", "https://example.com", false},
+		{"*.example.com", "https://badexample.com", false},
+		{"https://example.com", "https://example.com", true},
+		{"https://example.com", "http://example.com", false},
+		{"example.com:443", "https://example.com", true},
+		{"example.com:443", "http://example.com", false},
+		{"localhost:3000", "http://localhost:3000", true},
+		{"localhost:3000", "http://localhost:3001", false},
+		{"bücher.de", "https://xn--bcher-kva.de", true},
+		{"xn--bcher-kva.de", "https://BÜCHER.de", true},
+	}
+	for _, test := range tests {
+		pattern, err := ParseDomainPattern(test.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		origin, err := url.Parse(test.origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if matches := pattern.Matches(origin); matches != test.matches {
+			t.Errorf("%q matches %q = %v, want %v", test.pattern, test.origin, matches, test.matches)
+		}
+	}
+}
+
+func TestMatchesAllowedDomain(t *testing.T) {
+	origin, _ := url.Parse("https://shop.example.com")
+	if !MatchesAllowedDomain([]string{"other.org", "*.example.com"}, origin) {
+		t.Error("expected the wildcard to match")
+	}
+	if MatchesAllowedDomain([]string{"other.org", "not a domain ::"}, origin) {
+		t.Error("expected no match")
+	}
+	if MatchesAllowedDomain(nil, origin) || MatchesAllowedDomain([]string{"example.com"}, nil) {
+		t.Error("empty inputs never match")
+	}
+}
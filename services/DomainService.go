@@ -3,25 +3,54 @@ package services
 import (
 	"core/config"
 	"core/models"
+	"core/utils"
 	"log"
 )
 
+func normalizeDomainPattern(domain string) (string, bool) {
+	pattern, err := ParseDomainPattern(domain)
+	if err != nil {
+		return "", false
+	}
+	return pattern.String(), true
+}
+
 func CreateUserAllowedDomain(user *models.User, domain string) (bool, string) {
+	domain, ok := normalizeDomainPattern(domain)
+	if !ok {
+		return false, `Domain is not valid\! Please check it and try again\.`
+	}
 	var allowedDomain models.AllowedDomain
-	errForm := config.GetDB().Where("user_id = ? and name = ?", user.ID, domain).First(&allowedDomain)
+	errForm := config.GetDB().Where("user_id = ? and name = ? and form_token_uuid is null", user.ID, domain).First(&allowedDomain)
 	if errForm.RowsAffected == 0 {
+		token, err := getDomainVerificationToken(user.ID, domain)
+		if err != nil {
+			return false, `Error occurred\! Please try again\.`
+		}
 		allowedDomain = models.AllowedDomain{
-			Name:   domain,
-			UserID: user.ID,
+			Name:              domain,
+			UserID:            user.ID,
+			VerificationToken: token,
 		}
-		err := allowedDomain.Save()
+		inheritDomainVerification(&allowedDomain)
+		err = allowedDomain.Save()
 		if err != nil {
 			return false, `Error occurred\! Please try again\.`
 		}
 	} else {
 		return false, `Domain is exist for your user\! Please try another domain\.`
 	}
-	return true, ""
+	return true, GetDomainVerificationText(&allowedDomain)
+}
+
+// inheritDomainVerification marks a new record verified when the user already
+// verified the same domain for another scope.
+func inheritDomainVerification(domain *models.AllowedDomain) {
+	var verified models.AllowedDomain
+	result := config.GetDB().Where("user_id = ? and name = ? and verified_at is not null", domain.UserID, domain.Name).First(&verified)
+	if result.RowsAffected != 0 {
+		domain.VerifiedAt = verified.VerifiedAt
+	}
 }
 
 func GetDomains(userId uint64) []models.AllowedDomain {
@@ -42,3 +71,75 @@ func GetDomainsName(userId uint64) []string {
 	}
 	return domainsName
 }
+
+func CreateFormAllowedDomain(formToken *models.FormToken, domain string) (bool, string) {
+	domain, ok := normalizeDomainPattern(domain)
+	if !ok {
+		return false, `Domain is not valid\! Please check it and try again\.`
+	}
+	var allowedDomain models.AllowedDomain
+	errForm := config.GetDB().Where("form_token_uuid = ? and name = ?", formToken.Uuid, domain).First(&allowedDomain)
+	if errForm.RowsAffected != 0 {
+		return false, `Domain is already attached to this form\! Please try another domain\.`
+	}
+	token, err := getDomainVerificationToken(formToken.UserID, domain)
+	if err != nil {
+		return false, `Error occurred\! Please try again\.`
+	}
+	allowedDomain = models.AllowedDomain{
+		Name:              domain,
+		UserID:            formToken.UserID,
+		FormTokenUuid:     &formToken.Uuid,
+		VerificationToken: token,
+	}
+	inheritDomainVerification(&allowedDomain)
+	if err := allowedDomain.Save(); err != nil {
+		return false, `Error occurred\! Please try again\.`
+	}
+	return true, GetDomainVerificationText(&allowedDomain)
+}
+
+func DeleteFormAllowedDomain(formToken *models.FormToken, domain string) int64 {
+	if normalized, ok := normalizeDomainPattern(domain); ok {
+		domain = normalized
+	}
+	result := config.GetDB().Where("form_token_uuid = ? and name = ?", formToken.Uuid, domain).Delete(&models.AllowedDomain{})
+	if result.Error != nil {
+		log.Println("Error deleting form domain:", result.Error)
+	}
+	return result.RowsAffected
+}
+
+func DeleteFormDomains(formToken *models.FormToken) {
+	if err := config.GetDB().Where("form_token_uuid = ?", formToken.Uuid).Delete(&models.AllowedDomain{}).Error; err != nil {
+		log.Println("Error deleting form domains:", err)
+	}
+}
+
+// GetFormDomainsName returns the domains attached to the form, or the owner's
+// account-wide domains when the form has none of its own. Unverified domains
+// are left out unless DOMAIN_VERIFICATION_REQUIRED is off.
+func GetFormDomainsName(formToken *models.FormToken) []string {
+	var domains []models.AllowedDomain
+	err := config.GetDB().Where("form_token_uuid = ?", formToken.Uuid).Find(&domains).Error
+	if err != nil {
+		log.Println("Error fetching form domains:", err)
+		return nil
+	}
+	if len(domains) == 0 {
+		err = config.GetDB().Where("user_id = ? and form_token_uuid is null", formToken.UserID).Find(&domains).Error
+		if err != nil {
+			log.Println("Error fetching domains:", err)
+			return nil
+		}
+	}
+	verificationRequired := utils.GetEnvBool("DOMAIN_VERIFICATION_REQUIRED", true)
+	var domainsName []string
+	for _, domain := range domains {
+		if verificationRequired && domain.VerifiedAt == nil {
+			continue
+		}
+		domainsName = append(domainsName, domain.Name)
+	}
+	return domainsName
+}
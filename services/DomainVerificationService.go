@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"core/config"
+	"core/models"
+	"core/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DOMAIN_VERIFICATION_PREFIX = "formy-verification="
+	DOMAIN_VERIFICATION_PATH   = "/.well-known/formy-verification.txt"
+)
+
+var DOMAIN_VERIFICATION_TIMEOUT = 10 * time.Second
+
+// TXTResolver looks up DNS TXT records. *net.Resolver implements it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DomainVerifier checks that the owner of a domain published its verification
+// token, either as a DNS TXT record of the host or in the well-known file.
+// Resolver and Client can be swapped to test against local fakes.
+type DomainVerifier struct {
+	Resolver TXTResolver
+	Client   *http.Client
+}
+
+// The well-known file lives on a host the user chose, so it is fetched with a
+// client that only reaches public addresses.
+var DomainVerification = &DomainVerifier{
+	Resolver: net.DefaultResolver,
+	Client:   utils.NewPublicHTTPClient(DOMAIN_VERIFICATION_TIMEOUT),
+}
+
+// ErrDomainVerificationFailed is all the user learns about a failed check, so
+// the verifier can't be used to probe hosts and ports.
+var ErrDomainVerificationFailed = errors.New("the token was not found in a TXT record or the well-known file")
+
+func GenerateDomainVerificationToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// getDomainVerificationToken reuses the token of another record of the same
+// user and domain, so one published token verifies all of them.
+func getDomainVerificationToken(userId uint64, name string) (string, error) {
+	var domain models.AllowedDomain
+	result := config.GetDB().Where("user_id = ? and name = ? and verification_token <> ''", userId, name).First(&domain)
+	if result.RowsAffected != 0 {
+		return domain.VerificationToken, nil
+	}
+	return GenerateDomainVerificationToken()
+}
+
+func (verifier *DomainVerifier) Verify(domain *models.AllowedDomain) error {
+	pattern, err := ParseDomainPattern(domain.Name)
+	if err != nil {
+		return err
+	}
+	if allowLocalDomains() && isLoopbackHost(pattern.Host) {
+		return nil
+	}
+	if domain.VerificationToken == "" {
+		return errors.New("domain has no verification token")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DOMAIN_VERIFICATION_TIMEOUT)
+	defer cancel()
+
+	records, dnsErr := verifier.Resolver.LookupTXT(ctx, pattern.Host)
+	for _, record := range records {
+		if strings.TrimSpace(record) == DOMAIN_VERIFICATION_PREFIX+domain.VerificationToken {
+			return nil
+		}
+	}
+	fileErr := verifier.checkWellKnownFile(ctx, pattern, domain.VerificationToken)
+	if fileErr == nil {
+		return nil
+	}
+	log.Printf("Domain => Verification of %s failed: TXT lookup: %v, well-known file: %v", domain.Name, dnsErr, fileErr)
+	return ErrDomainVerificationFailed
+}
+
+// allowLocalDomains lets localhost and loopback addresses pass verification
+// without a token. It is meant for development only.
+func allowLocalDomains() bool {
+	return utils.GetEnvBool("DOMAIN_VERIFICATION_ALLOW_LOCAL", false)
+}
+
+func wellKnownURL(pattern DomainPattern) string {
+	scheme := pattern.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	host := pattern.Host
+	if pattern.Port != "" {
+		host = net.JoinHostPort(host, pattern.Port)
+	}
+	return scheme + "://" + host + DOMAIN_VERIFICATION_PATH
+}
+
+func (verifier *DomainVerifier) checkWellKnownFile(ctx context.Context, pattern DomainPattern, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnownURL(pattern), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := verifier.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("verification file not reachable: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("verification file returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	content := strings.TrimPrefix(strings.TrimSpace(string(body)), DOMAIN_VERIFICATION_PREFIX)
+	if content != token {
+		return errors.New("verification file doesn't contain the token")
+	}
+	return nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// VerifyUserDomain verifies every record of a user's domain and returns the
+// number of records that were marked verified.
+func VerifyUserDomain(user *models.User, name string) (int64, error) {
+	if normalized, ok := normalizeDomainPattern(name); ok {
+		name = normalized
+	}
+	var domain models.AllowedDomain
+	result := config.GetDB().Where("user_id = ? and name = ?", user.ID, name).First(&domain)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if err := DomainVerification.Verify(&domain); err != nil {
+		return 0, err
+	}
+	result = config.GetDB().Model(&models.AllowedDomain{}).
+		Where("user_id = ? and name = ?", user.ID, name).
+		Update("verified_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+func GetDomainVerificationText(domain *models.AllowedDomain) string {
+	if domain.VerifiedAt != nil {
+		return "Verified on " + domain.VerifiedAt.Format("2006-01-02") + "."
+	}
+	pattern, err := ParseDomainPattern(domain.Name)
+	if err != nil {
+		return "Domain is not valid."
+	}
+	if allowLocalDomains() && isLoopbackHost(pattern.Host) {
+		return "Local domains are verified as soon as you run /verify_domain " + domain.Name
+	}
+	return fmt.Sprintf("Not verified yet. Publish one of these, then run /verify_domain %s\n\n"+
+		"DNS TXT record of %s:\n%s%s\n\nOr the file %s containing:\n%s",
+		domain.Name, pattern.Host, DOMAIN_VERIFICATION_PREFIX, domain.VerificationToken,
+		wellKnownURL(pattern), domain.VerificationToken)
+}
+
+// MigrateDomainVerificationTokens gives domains added before verification
+// existed a token and marks them verified, so forms that already accept
+// submissions keep working after an upgrade.
+func MigrateDomainVerificationTokens() error {
+	var domains []models.AllowedDomain
+	if err := config.GetDB().Where("coalesce(verification_token, '') = ''").Find(&domains).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, domain := range domains {
+		token, err := getDomainVerificationToken(domain.UserID, domain.Name)
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"verification_token": token, "verified_at": now}
+		if err := config.GetDB().Model(&domain).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"core/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeResolver map[string][]string
+
+func (resolver fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := resolver[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return records, nil
+}
+
+// newWellKnownServer serves body at the verification path and 404 elsewhere.
+func newWellKnownServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != DOMAIN_VERIFICATION_PATH {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDomainVerifierTXTRecord(t *testing.T) {
+	verifier := &DomainVerifier{
+		Resolver: fakeResolver{"example.com": {"v=spf1 -all", "formy-verification=token"}},
+		Client:   http.DefaultClient,
+	}
+	for _, name := range []string{"example.com", "*.example.com", "https://example.com:8443"} {
+		domain := &models.AllowedDomain{Name: name, VerificationToken: "token"}
+		if err := verifier.Verify(domain); err != nil {
+			t.Errorf("%s: expected the TXT record to verify, got %v", name, err)
+		}
+	}
+	wrongToken := &models.AllowedDomain{Name: "example.com", VerificationToken: "other"}
+	if err := verifier.Verify(wrongToken); !errors.Is(err, ErrDomainVerificationFailed) {
+		t.Errorf("expected ErrDomainVerificationFailed, got %v", err)
+	}
+}
+
+func TestDomainVerifierWellKnownFile(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		ok   bool
+	}{
+		{"plain token", "token\n", true},
+		{"prefixed token", "formy-verification=token", true},
+		{"other token", "other", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newWellKnownServer(t, test.body)
+			verifier := &DomainVerifier{Resolver: fakeResolver{}, Client: server.Client()}
+			domain := &models.AllowedDomain{Name: server.URL, VerificationToken: "token"}
+			if err := verifier.Verify(domain); (err == nil) != test.ok {
+				t.Errorf("Verify() = %v", err)
+			}
+		})
+	}
+}
+
+func TestDomainVerifierHidesFailureDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+	verifier := &DomainVerifier{Resolver: fakeResolver{}, Client: server.Client()}
+	err := verifier.Verify(&models.AllowedDomain{Name: server.URL, VerificationToken: "token"})
+	if err != ErrDomainVerificationFailed {
+		t.Errorf("expected only the generic error, got %v", err)
+	}
+}
+
+func TestDomainVerifierRefusesInternalAddresses(t *testing.T) {
+	server := newWellKnownServer(t, "token")
+	// The default verifier must not reach the loopback server, even with the right token
+	verifier := &DomainVerifier{Resolver: fakeResolver{}, Client: DomainVerification.Client}
+	if err := verifier.Verify(&models.AllowedDomain{Name: server.URL, VerificationToken: "token"}); err == nil {
+		t.Error("expected the loopback well-known file to be refused")
+	}
+}
+
+func TestDomainVerifierDoesNotFollowRedirects(t *testing.T) {
+	target := newWellKnownServer(t, "token")
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+DOMAIN_VERIFICATION_PATH, http.StatusFound)
+	}))
+	defer redirect.Close()
+	client := *redirect.Client()
+	client.CheckRedirect = DomainVerification.Client.CheckRedirect
+	verifier := &DomainVerifier{Resolver: fakeResolver{}, Client: &client}
+	if err := verifier.Verify(&models.AllowedDomain{Name: redirect.URL, VerificationToken: "token"}); err == nil {
+		t.Error("expected the redirect not to be followed")
+	}
+}
+
+func TestDomainVerifierLocalDomains(t *testing.T) {
+	verifier := &DomainVerifier{Resolver: fakeResolver{}, Client: DomainVerification.Client}
+	domain := &models.AllowedDomain{Name: "localhost:3000", VerificationToken: "token"}
+	if err := verifier.Verify(domain); err == nil {
+		t.Error("local domains need a token unless the dev flag is set")
+	}
+	t.Setenv("DOMAIN_VERIFICATION_ALLOW_LOCAL", "true")
+	if err := verifier.Verify(domain); err != nil {
+		t.Errorf("local domains should pass with the dev flag, got %v", err)
+	}
+}
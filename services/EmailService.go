@@ -0,0 +1,161 @@
+package services
+
+import (
+	"core/models"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"html"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpRootCAs verifies the certificate offered after STARTTLS. Nil uses the
+// system roots.
+var smtpRootCAs *x509.CertPool
+
+func smtpAddress() string {
+	return net.JoinHostPort(os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"))
+}
+
+func smtpFrom() *mail.Address {
+	from, err := mail.ParseAddress(os.Getenv("SMTP_FROM"))
+	if err != nil {
+		return &mail.Address{Address: os.Getenv("SMTP_FROM")}
+	}
+	return from
+}
+
+func BuildEmailMessage(to string, subject string, htmlBody string) string {
+	var builder strings.Builder
+	from := smtpFrom()
+	builder.WriteString("From: " + from.String() + "\r\n")
+	builder.WriteString("To: " + to + "\r\n")
+	builder.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	builder.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+	builder.WriteString(fmt.Sprintf("Message-ID: <%s@%s>\r\n", uuid.New(), domain))
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n")
+	builder.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	builder.WriteString("\r\n")
+	builder.WriteString(htmlBody)
+	return builder.String()
+}
+
+func SendEmail(to string, message string) error {
+	host := os.Getenv("SMTP_HOST")
+	client, err := smtp.Dial(smtpAddress())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	startTLS, _ := strconv.ParseBool(os.Getenv("SMTP_STARTTLS"))
+	if startTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: host, RootCAs: smtpRootCAs}); err != nil {
+			return err
+		}
+	}
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		auth := smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(smtpFrom().Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write([]byte(message)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func classifyEmailError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var smtpErr *textproto.Error
+	// 5xx replies (unknown mailbox, rejected sender) are permanent, 4xx are worth retrying
+	if errors.As(err, &smtpErr) && smtpErr.Code >= 500 {
+		return &DeliveryError{Err: err, Permanent: true}
+	}
+	return &DeliveryError{Err: err}
+}
+
+type EmailNotifier struct{}
+
+// ValidateTarget accepts a bare address only. Display names and angle
+// brackets would otherwise end up in the RCPT command and the To header.
+func (EmailNotifier) ValidateTarget(target string) error {
+	parsed, err := mail.ParseAddress(target)
+	if err != nil || parsed.Name != "" || parsed.Address != target {
+		return fmt.Errorf("invalid email address %q", target)
+	}
+	return nil
+}
+
+func (EmailNotifier) Format(formToken *models.FormToken, submission *models.Submission, target string) ([]models.Delivery, error) {
+	to, err := mail.ParseAddress(target)
+	if err != nil {
+		return nil, err
+	}
+	subject := html.UnescapeString(GetSubmissionSubject(submission))
+	return []models.Delivery{{Payload: BuildEmailMessage(to.Address, subject, createHTMLBody(submission))}}, nil
+}
+
+func (EmailNotifier) Send(delivery *models.Delivery) error {
+	to, err := mail.ParseAddress(delivery.Target)
+	if err != nil {
+		return &DeliveryError{Err: fmt.Errorf("invalid email address %q", delivery.Target), Permanent: true}
+	}
+	return classifyEmailError(SendEmail(to.Address, delivery.Payload))
+}
+
+func createHTMLBody(submission *models.Submission) string {
+	template := ReadMailTemplate("/views/form-template.html")
+	tableData := ""
+	for _, key := range submission.OrderedKeys() {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		tableData += fmt.Sprintf("<tr><td>%s</td><td>%s</td></tr>", html.EscapeString(key), strings.Join(submission.Fields.Values(key), ", "))
+	}
+	for _, file := range submission.Files {
+		tableData += fmt.Sprintf("<tr><td>%s</td><td>%s (%d bytes)</td></tr>",
+			html.EscapeString(file.FieldName), html.EscapeString(file.FileName), file.Size)
+	}
+	return strings.Replace(template, "%s", tableData, -1)
+}
+
+func ReadMailTemplate(path string) string {
+	pwd, _ := os.Getwd()
+	templateFile, err := os.ReadFile(pwd + path)
+	if err != nil {
+		fmt.Print("Error on reading email template.")
+	}
+	return string(templateFile)
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"bufio"
+	"core/models"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTPServer speaks just enough SMTP for SendEmail: EHLO, STARTTLS,
+// AUTH PLAIN, MAIL, RCPT, DATA and QUIT.
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	rcptReply string
+
+	mu       sync.Mutex
+	startTLS bool
+	auth     string
+	from     string
+	rcpt     string
+	data     string
+}
+
+func newFakeSMTPServer(t *testing.T, rcptReply string) (*fakeSMTPServer, *x509.CertPool) {
+	// Borrow the certificate httptest issues for 127.0.0.1
+	certServer := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(certServer.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(certServer.Certificate())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeSMTPServer{
+		listener:  listener,
+		tlsConfig: &tls.Config{Certificates: certServer.TLS.Certificates},
+		rcptReply: rcptReply,
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("SMTP_FROM", "Formy <noreply@example.com>")
+	return server, roots
+}
+
+func (server *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 fake ESMTP")
+	secure := false
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if secure {
+				text.PrintfLine("250-fake\r\n250 AUTH PLAIN")
+			} else {
+				text.PrintfLine("250-fake\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			text.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, server.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			text = textproto.NewConn(conn)
+			server.record(func() { server.startTLS = true })
+		case "AUTH":
+			_, encoded, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			server.record(func() { server.auth = string(decoded) })
+			text.PrintfLine("235 accepted")
+		case "MAIL":
+			server.record(func() { server.from = arg })
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			server.record(func() { server.rcpt = arg })
+			text.PrintfLine(server.rcptReply)
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			server.record(func() { server.data = string(data) })
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (server *fakeSMTPServer) record(update func()) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	update()
+}
+
+func useSMTPRootCAs(t *testing.T, roots *x509.CertPool) {
+	defaultRoots := smtpRootCAs
+	smtpRootCAs = roots
+	t.Cleanup(func() { smtpRootCAs = defaultRoots })
+}
+
+func TestSendEmailOverStartTLS(t *testing.T) {
+	server, roots := newFakeSMTPServer(t, "250 ok")
+	useSMTPRootCAs(t, roots)
+	t.Setenv("SMTP_STARTTLS", "true")
+	t.Setenv("SMTP_USERNAME", "formy")
+	t.Setenv("SMTP_PASSWORD", "hunter2")
+
+	delivery := &models.Delivery{
+		Target:  "owner@example.com",
+		Payload: BuildEmailMessage("owner@example.com", "New form submission", "<p>Hello</p>\r\n.leading dot"),
+	}
+	if err := (EmailNotifier{}).Send(delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !server.startTLS {
+		t.Error("the connection was not upgraded with STARTTLS")
+	}
+	if server.auth != "\x00formy\x00hunter2" {
+		t.Errorf("auth = %q", server.auth)
+	}
+	if server.from != "FROM:<noreply@example.com>" || server.rcpt != "TO:<owner@example.com>" {
+		t.Errorf("envelope = %q -> %q", server.from, server.rcpt)
+	}
+	message := bufio.NewReader(strings.NewReader(server.data))
+	header, err := textproto.NewReader(message).ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("To") != "owner@example.com" || header.Get("From") != `"Formy" <noreply@example.com>` {
+		t.Errorf("headers = %v", header)
+	}
+	if !strings.Contains(server.data, "<p>Hello</p>\n.leading dot") {
+		t.Errorf("body was not delivered intact: %q", server.data)
+	}
+}
+
+func TestSendEmailClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		rcptReply string
+		startTLS  string
+		permanent bool
+	}{
+		{"unknown mailbox", "550 no such user", "false", true},
+		{"greylisted", "451 try again later", "false", false},
+		{"untrusted certificate", "250 ok", "true", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newFakeSMTPServer(t, test.rcptReply)
+			t.Setenv("SMTP_STARTTLS", test.startTLS)
+			t.Setenv("SMTP_USERNAME", "")
+
+			err := (EmailNotifier{}).Send(&models.Delivery{Target: "owner@example.com", Payload: "Subject: hi\r\n\r\nhi"})
+			var deliveryErr *DeliveryError
+			if !errors.As(err, &deliveryErr) {
+				t.Fatalf("expected a DeliveryError, got %v", err)
+			}
+			if deliveryErr.Permanent != test.permanent {
+				t.Errorf("permanent = %v, want %v (%v)", deliveryErr.Permanent, test.permanent, err)
+			}
+		})
+	}
+}
+
+func TestSendEmailRetriesUnreachableServers(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+
+	var deliveryErr *DeliveryError
+	err = (EmailNotifier{}).Send(&models.Delivery{Target: "owner@example.com", Payload: "hi"})
+	if !errors.As(err, &deliveryErr) || deliveryErr.Permanent {
+		t.Errorf("a refused connection should be retried, got %v", err)
+	}
+}
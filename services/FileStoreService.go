@@ -0,0 +1,207 @@
+package services
+
+import (
+	"core/models"
+	"core/utils"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	UPLOAD_DEFAULT_MAX_FILES     = 3
+	UPLOAD_DEFAULT_MAX_FILE_SIZE = int64(5 << 20)
+	UPLOAD_DEFAULT_MIME_TYPES    = []string{"image/*", "application/pdf", "text/plain"}
+)
+
+type FileStore interface {
+	Save(key string, content io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+type LocalFileStore struct {
+	Root string
+}
+
+func (store LocalFileStore) path(key string) (string, error) {
+	path := filepath.Join(store.Root, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(store.Root)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid file key %q", key)
+	}
+	return path, nil
+}
+
+func (store LocalFileStore) Save(key string, content io.Reader) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
+
+func (store LocalFileStore) Open(key string) (io.ReadCloser, error) {
+	path, err := store.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (store LocalFileStore) Delete(key string) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+var Files FileStore
+
+func InitFileStore() {
+	UPLOAD_DEFAULT_MAX_FILES = utils.GetEnvInt("UPLOAD_MAX_FILES", UPLOAD_DEFAULT_MAX_FILES)
+	UPLOAD_DEFAULT_MAX_FILE_SIZE = utils.GetEnvInt64("UPLOAD_MAX_FILE_SIZE", UPLOAD_DEFAULT_MAX_FILE_SIZE)
+	UPLOAD_DEFAULT_MIME_TYPES = utils.GetEnvList("UPLOAD_MIME_TYPES", UPLOAD_DEFAULT_MIME_TYPES)
+	SetFileStore(LocalFileStore{Root: utils.GetEnvString("UPLOAD_DIR", "uploads")})
+}
+
+func SetFileStore(store FileStore) {
+	Files = store
+}
+
+type UploadLimits struct {
+	MaxFiles    int
+	MaxFileSize int64
+	MimeTypes   []string
+}
+
+func GetUploadLimits(formToken *models.FormToken) UploadLimits {
+	limits := UploadLimits{
+		MaxFiles:    UPLOAD_DEFAULT_MAX_FILES,
+		MaxFileSize: UPLOAD_DEFAULT_MAX_FILE_SIZE,
+		MimeTypes:   UPLOAD_DEFAULT_MIME_TYPES,
+	}
+	if formToken.UploadMaxFiles != nil {
+		limits.MaxFiles = *formToken.UploadMaxFiles
+	}
+	if formToken.UploadMaxFileSize != nil {
+		limits.MaxFileSize = *formToken.UploadMaxFileSize
+	}
+	if formToken.UploadMimeTypes != "" {
+		limits.MimeTypes = utils.SplitList(formToken.UploadMimeTypes)
+	}
+	return limits
+}
+
+func (limits UploadLimits) allowsMimeType(mimeType string) bool {
+	for _, pattern := range limits.MimeTypes {
+		if pattern == "*/*" || pattern == mimeType {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+type UploadError struct {
+	Message string
+}
+
+func (e *UploadError) Error() string {
+	return e.Message
+}
+
+// StoreUploadedFiles checks the uploaded files against the form's limits and
+// writes them to the file store. Stored files are removed again on error.
+func StoreUploadedFiles(formToken *models.FormToken, form *multipart.Form) ([]models.SubmissionFile, error) {
+	if form == nil || len(form.File) == 0 {
+		return nil, nil
+	}
+	limits := GetUploadLimits(formToken)
+	var headers []*multipart.FileHeader
+	var fieldNames []string
+	for fieldName, fieldHeaders := range form.File {
+		for _, header := range fieldHeaders {
+			headers = append(headers, header)
+			fieldNames = append(fieldNames, fieldName)
+		}
+	}
+	if len(headers) > limits.MaxFiles {
+		return nil, &UploadError{Message: fmt.Sprintf("You can upload at most %d files.", limits.MaxFiles)}
+	}
+
+	var files []models.SubmissionFile
+	for i, header := range headers {
+		if header.Size > limits.MaxFileSize {
+			DeleteStoredFiles(files)
+			return nil, &UploadError{Message: fmt.Sprintf("File %s is larger than %d bytes.", header.Filename, limits.MaxFileSize)}
+		}
+		file, err := storeUploadedFile(formToken, fieldNames[i], header, limits)
+		if err != nil {
+			DeleteStoredFiles(files)
+			return nil, err
+		}
+		files = append(files, *file)
+	}
+	return files, nil
+}
+
+func storeUploadedFile(formToken *models.FormToken, fieldName string, header *multipart.FileHeader, limits UploadLimits) (*models.SubmissionFile, error) {
+	content, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	// Sniff the type from the content instead of trusting the client's header
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(content, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	mimeType := strings.Split(http.DetectContentType(sniff[:n]), ";")[0]
+	if !limits.allowsMimeType(mimeType) {
+		return nil, &UploadError{Message: fmt.Sprintf("File type %s is not allowed.", mimeType)}
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s/%s", formToken.Uuid, uuid.New())
+	if err := Files.Save(key, content); err != nil {
+		return nil, err
+	}
+	return &models.SubmissionFile{
+		FieldName:  fieldName,
+		FileName:   filepath.Base(header.Filename),
+		MimeType:   mimeType,
+		Size:       header.Size,
+		StorageKey: key,
+	}, nil
+}
+
+func DeleteStoredFiles(files []models.SubmissionFile) {
+	for _, file := range files {
+		Files.Delete(file.StorageKey)
+	}
+}
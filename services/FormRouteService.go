@@ -0,0 +1,83 @@
+package services
+
+import (
+	"core/config"
+	"core/models"
+	"gorm.io/gorm"
+	"log"
+	"slices"
+	"strings"
+)
+
+func CreateFormRoute(formToken *models.FormToken, channel string, target string) (bool, string) {
+	notifier, ok := GetNotifier(channel)
+	if !ok {
+		channels := GetNotifierChannels()
+		slices.Sort(channels)
+		return false, "Unknown channel\\! Available channels: " + strings.Join(channels, ", ") + "\\."
+	}
+	if len(target) > models.FormRouteTargetMaxLength || notifier.ValidateTarget(target) != nil {
+		return false, `Target is not valid for this channel\! Please check it and try again\.`
+	}
+
+	var route models.FormRoute
+	errRoute := config.GetDB().Where("form_token_uuid = ? and channel = ? and target = ?", formToken.Uuid, channel, target).First(&route)
+	if errRoute.RowsAffected != 0 {
+		return false, `Route is exist for this form\! Please try another target\.`
+	}
+
+	if channel == models.DeliveryChannelWebhook && formToken.WebhookSecret == "" {
+		secret, err := GenerateWebhookSecret()
+		if err != nil {
+			return false, `Error occurred\! Please try again\.`
+		}
+		formToken.WebhookSecret = secret
+		if err := formToken.Save(); err != nil {
+			return false, `Error occurred\! Please try again\.`
+		}
+	}
+
+	route = models.FormRoute{
+		FormTokenUuid: formToken.Uuid,
+		Channel:       channel,
+		Target:        target,
+	}
+	if err := route.Save(); err != nil {
+		return false, `Error occurred\! Please try again\.`
+	}
+	return true, ""
+}
+
+func DeleteFormRoutes(formToken *models.FormToken, channel string) int64 {
+	result := config.GetDB().Where("form_token_uuid = ? and channel = ?", formToken.Uuid, channel).Delete(&models.FormRoute{})
+	if result.Error != nil {
+		log.Println("Error deleting form routes:", result.Error)
+	}
+	return result.RowsAffected
+}
+
+// MigrateLegacyRoutes moves the email and webhook columns that used to live on
+// form_tokens into the routing table.
+func MigrateLegacyRoutes() error {
+	return config.GetDB().Transaction(func(tx *gorm.DB) error {
+		for column, channel := range map[string]string{
+			"email":       models.DeliveryChannelEmail,
+			"webhook_url": models.DeliveryChannelWebhook,
+		} {
+			if !tx.Migrator().HasColumn(&models.FormToken{}, column) {
+				continue
+			}
+			err := tx.Exec(
+				"insert into form_routes (form_token_uuid, channel, target) select uuid, ?, "+column+" from form_tokens where coalesce("+column+", '') <> ''",
+				channel,
+			).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropColumn(&models.FormToken{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"core/models"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func longURL(length int) string {
+	base := "https://hooks.example.com/formy?token="
+	return base + strings.Repeat("a", length-len(base))
+}
+
+func TestLongTargetsFitTheDeliveryColumn(t *testing.T) {
+	want := fmt.Sprintf("varchar(%d)", models.FormRouteTargetMaxLength)
+	for _, model := range []interface{}{&models.FormRoute{}, &models.Delivery{}} {
+		parsed, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if field := parsed.LookUpField("Target"); string(field.DataType) != want {
+			t.Errorf("%s.Target is %s, want %s", parsed.Name, field.DataType, want)
+		}
+	}
+
+	target := longURL(1000)
+	for _, notifier := range []Notifier{WebhookNotifier{}, SlackNotifier{}} {
+		if err := notifier.ValidateTarget(target); err != nil {
+			t.Errorf("%T: a %d character URL should be accepted, got %v", notifier, len(target), err)
+		}
+	}
+}
+
+func TestCreateFormRouteRejectsOverlongTargets(t *testing.T) {
+	formToken := &models.FormToken{Name: "contact"}
+	ok, msgText := CreateFormRoute(formToken, models.DeliveryChannelWebhook, longURL(models.FormRouteTargetMaxLength+1))
+	if ok || !strings.Contains(msgText, "not valid") {
+		t.Errorf("expected an overlong target to be rejected, got %v %q", ok, msgText)
+	}
+}
@@ -18,6 +18,15 @@ func GetFormTokens(user *models.User) []models.FormToken {
 	return formTokens
 }
 
+func GetUserFormToken(user *models.User, formName string) (*models.FormToken, error) {
+	var formToken models.FormToken
+	err := config.GetDB().Where("user_id = ? and name = ?", user.ID, formName).First(&formToken).Error
+	if err != nil {
+		return nil, err
+	}
+	return &formToken, nil
+}
+
 func CreateUserFormToken(update tgbotapi.Update, user *models.User, formName string) (bool, string) {
 	var formToken models.FormToken
 	errForm := config.GetDB().Where("user_id = ? and name = ?", user.ID, formName).First(&formToken)
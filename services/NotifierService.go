@@ -0,0 +1,168 @@
+package services
+
+import (
+	"core/config"
+	"core/models"
+	"core/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var CCLIST_MAX_AMOUNT = 2
+
+type Notifier interface {
+	ValidateTarget(target string) error
+	Format(formToken *models.FormToken, submission *models.Submission, target string) ([]models.Delivery, error)
+	Send(delivery *models.Delivery) error
+}
+
+var notifiers = map[string]Notifier{}
+
+func RegisterNotifier(channel string, notifier Notifier) {
+	notifiers[channel] = notifier
+}
+
+func GetNotifier(channel string) (Notifier, bool) {
+	notifier, ok := notifiers[channel]
+	return notifier, ok
+}
+
+func GetNotifierChannels() []string {
+	channels := make([]string, 0, len(notifiers))
+	for channel := range notifiers {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+func init() {
+	RegisterNotifier(models.DeliveryChannelTelegram, TelegramNotifier{})
+	RegisterNotifier(models.DeliveryChannelEmail, EmailNotifier{})
+	RegisterNotifier(models.DeliveryChannelWebhook, WebhookNotifier{})
+	RegisterNotifier(models.DeliveryChannelSlack, SlackNotifier{})
+}
+
+func GetSubmissionSubject(submission *models.Submission) string {
+	subject := "New form submission"
+	if val := submission.Fields.String("_subject"); len(strings.TrimSpace(val)) > 0 {
+		subject = val
+	}
+	return subject
+}
+
+// ResolveFieldOrder puts the fields named in an "_order" hidden field first,
+// falling back to the form's configured order, followed by the posted order.
+func ResolveFieldOrder(formToken *models.FormToken, fields models.JSONData, postedOrder []string) []string {
+	preferred := utils.SplitList(html.UnescapeString(strings.Join(fields.Values("_order"), ",")))
+	if len(preferred) == 0 {
+		preferred = utils.SplitList(formToken.FieldOrder)
+	}
+	var order []string
+	seen := map[string]bool{}
+	for _, key := range append(preferred, postedOrder...) {
+		if _, ok := fields[key]; ok && !seen[key] {
+			order = append(order, key)
+			seen[key] = true
+		}
+	}
+	return order
+}
+
+func DispatchSubmission(formToken *models.FormToken, submission *models.Submission) error {
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(submission).Error; err != nil {
+			return err
+		}
+		if submission.Status == models.SubmissionStatusQuarantined {
+			return nil
+		}
+		return createDeliveries(tx, formToken, submission)
+	})
+	if err == nil && submission.Status != models.SubmissionStatusQuarantined {
+		WakeOutbox()
+	}
+	return err
+}
+
+func createDeliveries(tx *gorm.DB, formToken *models.FormToken, submission *models.Submission) error {
+	routes := []models.FormRoute{{
+		FormTokenUuid: formToken.Uuid,
+		Channel:       models.DeliveryChannelTelegram,
+		Target:        strconv.FormatInt(formToken.ChatID, 10),
+	}}
+	routes = append(routes, getCCRoutes(submission)...)
+	routes = append(routes, models.GetFormRoutes(formToken.Uuid)...)
+
+	var deliveries []models.Delivery
+	for _, route := range routes {
+		notifier, ok := GetNotifier(route.Channel)
+		if !ok {
+			log.Printf("Dispatch => No notifier registered for channel %s", route.Channel)
+			continue
+		}
+		formatted, err := notifier.Format(formToken, submission, route.Target)
+		if err != nil {
+			log.Printf("Dispatch => Error formatting %s delivery for submission %d: %v", route.Channel, submission.ID, err)
+			continue
+		}
+		for _, delivery := range formatted {
+			delivery.SubmissionID = submission.ID
+			delivery.Channel = route.Channel
+			delivery.Target = route.Target
+			delivery.Status = models.DeliveryStatusPending
+			delivery.NextAttemptAt = time.Now()
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return tx.Create(&deliveries).Error
+}
+
+func getCCRoutes(submission *models.Submission) []models.FormRoute {
+	var routes []models.FormRoute
+	ccValue := strings.Join(submission.Fields.Values("_cc"), ",")
+	if len(strings.TrimSpace(ccValue)) == 0 {
+		return nil
+	}
+	ccList := strings.SplitN(ccValue, ",", CCLIST_MAX_AMOUNT+1)
+	for i, to := range ccList {
+		if i >= CCLIST_MAX_AMOUNT {
+			break
+		}
+		ccFormToken, err := models.GetFormTokenByUuid(utils.GetUUIDFromString(strings.TrimSpace(to)))
+		if err != nil {
+			break
+		}
+		routes = append(routes, models.FormRoute{
+			FormTokenUuid: ccFormToken.Uuid,
+			Channel:       models.DeliveryChannelTelegram,
+			Target:        strconv.FormatInt(ccFormToken.ChatID, 10),
+		})
+	}
+	return routes
+}
+
+func reportDeliveryFailure(delivery *models.Delivery, deliveryErr error) {
+	submission, err := models.GetSubmissionById(delivery.SubmissionID)
+	if err != nil {
+		return
+	}
+	formToken, err := models.GetFormTokenByUuid(submission.FormTokenUuid)
+	if err != nil {
+		return
+	}
+	// A failing home chat can't be told about its own failure
+	if delivery.Channel == models.DeliveryChannelTelegram && delivery.Target == strconv.FormatInt(formToken.ChatID, 10) {
+		return
+	}
+	message := fmt.Sprintf("⚠️ <b>Delivery failed</b>\n\nSubmission #%d of <b>%s</b> could not be delivered via %s to %s:\n%s",
+		submission.ID, html.EscapeString(formToken.Name), delivery.Channel, html.EscapeString(delivery.Target), html.EscapeString(deliveryErr.Error()))
+	SendTelegramMessage(formToken.ChatID, message)
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"core/models"
+	"reflect"
+	"testing"
+)
+
+func TestResolveFieldOrder(t *testing.T) {
+	fields := models.JSONData{"name": "Ada", "email": "ada@example.com", "message": "Hi", "phone": "123"}
+	posted := []string{"name", "email", "message", "phone", "email"}
+	tests := []struct {
+		name       string
+		fields     models.JSONData
+		posted     []string
+		fieldOrder string
+		want       []string
+	}{
+		{"posted order", fields, posted, "", []string{"name", "email", "message", "phone"}},
+		{"form order first", fields, posted, "message, phone", []string{"message", "phone", "name", "email"}},
+		{"unknown names skipped", fields, posted, "missing,email", []string{"email", "name", "message", "phone"}},
+		{
+			"order field wins",
+			models.JSONData{"name": "Ada", "email": "ada@example.com", "_order": "email,name"},
+			[]string{"name", "email", "_order"},
+			"name",
+			[]string{"email", "name", "_order"},
+		},
+	}
+	for _, test := range tests {
+		got := ResolveFieldOrder(&models.FormToken{FieldOrder: test.fieldOrder}, test.fields, test.posted)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestOrderedKeysSortsUnorderedFields(t *testing.T) {
+	submission := &models.Submission{
+		Fields:     models.JSONData{"zeta": "1", "alpha": "2", "name": "3", "email": "4"},
+		FieldOrder: models.StringList{"name", "gone", "email", "name"},
+	}
+	for i := 0; i < 10; i++ {
+		got := submission.OrderedKeys()
+		if want := []string{"name", "email", "alpha", "zeta"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
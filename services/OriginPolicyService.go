@@ -0,0 +1,84 @@
+package services
+
+import (
+	"core/models"
+	"core/utils"
+	"github.com/gin-gonic/gin"
+	"net/url"
+	"strings"
+)
+
+var (
+	CORS_ALLOW_HEADERS = []string{"Content-Type", "Accept", "X-Requested-With"}
+	CORS_MAX_AGE       = "600"
+)
+
+type OriginError struct {
+	Message string
+}
+
+func (e *OriginError) Error() string {
+	return e.Message
+}
+
+// CheckFormOrigin applies the form's origin policy. The Origin header wins
+// over the Referer, and when both are sent they must agree on scheme and host.
+// Requests with neither header are rejected unless the owner accepts them, in
+// which case the returned URL is nil.
+func CheckFormOrigin(formToken *models.FormToken, c *gin.Context) (*url.URL, error) {
+	originHeader := c.Request.Header.Get("Origin")
+	refererHeader := c.Request.Header.Get("Referer")
+	if originHeader == "" && refererHeader == "" {
+		if formToken.AcceptMissingOrigin {
+			return nil, nil
+		}
+		return nil, &OriginError{Message: "Request origin is not valid."}
+	}
+
+	var origin *url.URL
+	if originHeader != "" {
+		origin = utils.ParseOriginURL(originHeader)
+		if origin == nil {
+			return nil, &OriginError{Message: "Request origin is not valid."}
+		}
+		if referer := utils.ParseOriginURL(refererHeader); referer != nil && !sameOrigin(origin, referer) {
+			return nil, &OriginError{Message: "Request origin is not valid."}
+		}
+	} else {
+		origin = utils.ParseOriginURL(refererHeader)
+	}
+	if origin == nil || (origin.Scheme != "http" && origin.Scheme != "https") {
+		return nil, &OriginError{Message: "Request origin is not valid."}
+	}
+	if !MatchesAllowedDomain(GetFormDomainsName(formToken), origin) {
+		return nil, &OriginError{Message: "This is not an allowed domain."}
+	}
+	return origin, nil
+}
+
+func sameOrigin(a *url.URL, b *url.URL) bool {
+	if !strings.EqualFold(a.Scheme, b.Scheme) || effectivePort(a.Scheme, a.Port()) != effectivePort(b.Scheme, b.Port()) {
+		return false
+	}
+	hostA, errA := NormalizeHost(a.Hostname())
+	hostB, errB := NormalizeHost(b.Hostname())
+	return errA == nil && errB == nil && hostA == hostB
+}
+
+// SetCORSHeaders lets the browser read the response when origin is allowed.
+// It never answers with "*", so only the form's own domains get access.
+func SetCORSHeaders(c *gin.Context, origin *url.URL) {
+	c.Header("Vary", "Origin")
+	if origin == nil || c.Request.Header.Get("Origin") == "" {
+		return
+	}
+	c.Header("Access-Control-Allow-Origin", origin.Scheme+"://"+origin.Host)
+}
+
+// SetPreflightHeaders answers a CORS preflight from an allowed origin.
+func SetPreflightHeaders(c *gin.Context, origin *url.URL) {
+	SetCORSHeaders(c, origin)
+	c.Header("Access-Control-Allow-Methods", "POST, OPTIONS")
+	c.Header("Access-Control-Allow-Headers", strings.Join(CORS_ALLOW_HEADERS, ", "))
+	c.Header("Access-Control-Max-Age", CORS_MAX_AGE)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"core/models"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+var (
+	OUTBOX_POLL_INTERVAL = 5 * time.Second
+	OUTBOX_BATCH_SIZE    = 20
+	OUTBOX_LEASE         = 2 * time.Minute
+	OUTBOX_MAX_ATTEMPTS  = 10
+	OUTBOX_BASE_BACKOFF  = 10 * time.Second
+	OUTBOX_MAX_BACKOFF   = 6 * time.Hour
+)
+
+var outboxWakeup = make(chan struct{}, 1)
+
+// DeliveryError tells the outbox how to go on after a failed send. Throttled
+// errors come from targets that asked to slow down, and their attempts don't
+// count towards OUTBOX_MAX_ATTEMPTS.
+type DeliveryError struct {
+	Err        error
+	Permanent  bool
+	Throttled  bool
+	RetryAfter time.Duration
+}
+
+func (e *DeliveryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+func StartOutboxWorker() {
+	go func() {
+		ticker := time.NewTicker(OUTBOX_POLL_INTERVAL)
+		defer ticker.Stop()
+		for {
+			processDueDeliveries()
+			select {
+			case <-ticker.C:
+			case <-outboxWakeup:
+			}
+		}
+	}()
+}
+
+func WakeOutbox() {
+	select {
+	case outboxWakeup <- struct{}{}:
+	default:
+	}
+}
+
+func processDueDeliveries() {
+	for {
+		deliveries, err := models.ClaimDueDeliveries(OUTBOX_BATCH_SIZE, OUTBOX_LEASE)
+		if err != nil {
+			log.Println("Outbox => Error claiming deliveries:", err)
+			return
+		}
+		for i := range deliveries {
+			processDelivery(&deliveries[i])
+		}
+		if len(deliveries) < OUTBOX_BATCH_SIZE {
+			return
+		}
+	}
+}
+
+func processDelivery(delivery *models.Delivery) {
+	err := sendDelivery(delivery)
+	if err == nil {
+		if err := delivery.MarkSent(); err != nil {
+			log.Printf("Outbox => Error marking delivery %d as sent: %v", delivery.ID, err)
+		}
+	} else {
+		if isFinalFailure(err, delivery.Attempts) {
+			log.Printf("Outbox => Delivery %d failed permanently after %d attempts: %v", delivery.ID, delivery.Attempts, err)
+			if err := delivery.MarkFailed(err.Error()); err != nil {
+				log.Printf("Outbox => Error marking delivery %d as failed: %v", delivery.ID, err)
+			}
+			reportDeliveryFailure(delivery, err)
+		} else {
+			var deliveryErr *DeliveryError
+			errors.As(err, &deliveryErr)
+			nextAttemptAt := time.Now().Add(retryDelay(delivery.Attempts, deliveryErr))
+			log.Printf("Outbox => Delivery %d failed, retrying at %s: %v", delivery.ID, nextAttemptAt.Format(time.RFC3339), err)
+			markRetry := delivery.MarkRetry
+			if deliveryErr != nil && deliveryErr.Throttled {
+				markRetry = delivery.MarkThrottled
+			}
+			if err := markRetry(err.Error(), nextAttemptAt); err != nil {
+				log.Printf("Outbox => Error scheduling retry for delivery %d: %v", delivery.ID, err)
+			}
+		}
+	}
+
+	submission, err := models.GetSubmissionById(delivery.SubmissionID)
+	if err != nil {
+		return
+	}
+	if err := submission.RefreshStatus(); err != nil {
+		log.Printf("Outbox => Error refreshing status of submission %d: %v", submission.ID, err)
+	}
+}
+
+// isFinalFailure reports whether a failed delivery should not be retried,
+// because the error is permanent or the attempts are used up. Throttled
+// errors are never final, as their attempts are handed back.
+func isFinalFailure(err error, attempts int) bool {
+	var deliveryErr *DeliveryError
+	if errors.As(err, &deliveryErr) {
+		if deliveryErr.Permanent {
+			return true
+		}
+		if deliveryErr.Throttled {
+			return false
+		}
+	}
+	return attempts >= OUTBOX_MAX_ATTEMPTS
+}
+
+func retryDelay(attempts int, deliveryErr *DeliveryError) time.Duration {
+	if deliveryErr != nil && deliveryErr.RetryAfter > 0 {
+		return deliveryErr.RetryAfter
+	}
+	delay := OUTBOX_MAX_BACKOFF
+	if attempts <= 30 {
+		delay = OUTBOX_BASE_BACKOFF << (attempts - 1)
+	}
+	if delay <= 0 || delay > OUTBOX_MAX_BACKOFF {
+		delay = OUTBOX_MAX_BACKOFF
+	}
+	// Add up to 20% jitter so retries of a burst don't hit the API at once
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+func sendDelivery(delivery *models.Delivery) error {
+	notifier, ok := GetNotifier(delivery.Channel)
+	if !ok {
+		return &DeliveryError{Err: fmt.Errorf("unknown delivery channel %q", delivery.Channel), Permanent: true}
+	}
+	return notifier.Send(delivery)
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRetryDelayBacksOffExponentially(t *testing.T) {
+	for attempts := 1; attempts <= 5; attempts++ {
+		base := OUTBOX_BASE_BACKOFF << (attempts - 1)
+		for i := 0; i < 20; i++ {
+			delay := retryDelay(attempts, nil)
+			if delay < base || delay > base+base/5 {
+				t.Fatalf("attempt %d: delay %s outside [%s, %s]", attempts, delay, base, base+base/5)
+			}
+		}
+	}
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	for _, attempts := range []int{20, 31, 64, 1000} {
+		delay := retryDelay(attempts, nil)
+		if delay < OUTBOX_MAX_BACKOFF || delay > OUTBOX_MAX_BACKOFF+OUTBOX_MAX_BACKOFF/5 {
+			t.Errorf("attempt %d: delay %s is not capped at %s", attempts, delay, OUTBOX_MAX_BACKOFF)
+		}
+	}
+}
+
+func TestRetryDelayHonorsRetryAfter(t *testing.T) {
+	delay := retryDelay(3, &DeliveryError{Err: errors.New("slow down"), RetryAfter: 42 * time.Second})
+	if delay != 42*time.Second {
+		t.Errorf("delay = %s, want 42s", delay)
+	}
+}
+
+func TestIsFinalFailure(t *testing.T) {
+	temporary := &DeliveryError{Err: errors.New("timeout")}
+	permanent := &DeliveryError{Err: errors.New("chat not found"), Permanent: true}
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+		final    bool
+	}{
+		{"temporary", temporary, 1, false},
+		{"wrapped temporary", fmt.Errorf("send: %w", temporary), 1, false},
+		{"plain error", errors.New("boom"), 1, false},
+		{"permanent", permanent, 1, true},
+		{"wrapped permanent", fmt.Errorf("send: %w", permanent), 1, true},
+		{"attempts used up", temporary, OUTBOX_MAX_ATTEMPTS, true},
+		{"throttled", &DeliveryError{Err: errors.New("too many requests"), Throttled: true}, OUTBOX_MAX_ATTEMPTS, false},
+	}
+	for _, test := range tests {
+		if final := isFinalFailure(test.err, test.attempts); final != test.final {
+			t.Errorf("%s: isFinalFailure = %v, want %v", test.name, final, test.final)
+		}
+	}
+}
+
+func TestClassifyTelegramError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		permanent  bool
+		throttled  bool
+		retryAfter time.Duration
+	}{
+		{"network", errors.New("connection reset"), false, false, 0},
+		{"flood control", &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 30}}, false, true, 30 * time.Second},
+		{"chat not found", &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, true, false, 0},
+		{"bot blocked", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, true, false, 0},
+		{"server error", &tgbotapi.Error{Code: 502, Message: "Bad Gateway"}, false, false, 0},
+	}
+	for _, test := range tests {
+		var deliveryErr *DeliveryError
+		if !errors.As(classifyTelegramError(test.err), &deliveryErr) {
+			t.Fatalf("%s: expected a DeliveryError", test.name)
+		}
+		if deliveryErr.Permanent != test.permanent || deliveryErr.Throttled != test.throttled || deliveryErr.RetryAfter != test.retryAfter {
+			t.Errorf("%s: got %+v", test.name, deliveryErr)
+		}
+	}
+	if classifyTelegramError(nil) != nil {
+		t.Error("no error should stay nil")
+	}
+}
+
+func TestClassifyEmailError(t *testing.T) {
+	var deliveryErr *DeliveryError
+	errors.As(classifyEmailError(&textproto.Error{Code: 550, Msg: "mailbox unavailable"}), &deliveryErr)
+	if deliveryErr == nil || !deliveryErr.Permanent {
+		t.Error("5xx replies should be permanent")
+	}
+	deliveryErr = nil
+	errors.As(classifyEmailError(&textproto.Error{Code: 451, Msg: "try again later"}), &deliveryErr)
+	if deliveryErr == nil || deliveryErr.Permanent {
+		t.Error("4xx replies should be retried")
+	}
+}
+
+func TestSendWebhookClassifiesResponses(t *testing.T) {
+	tests := []struct {
+		status     int
+		retryAfter string
+		ok         bool
+		permanent  bool
+		wait       time.Duration
+	}{
+		{http.StatusNoContent, "", true, false, 0},
+		{http.StatusInternalServerError, "", false, false, 0},
+		{http.StatusServiceUnavailable, "120", false, false, 2 * time.Minute},
+		{http.StatusGone, "", false, true, 0},
+		{http.StatusFound, "", false, false, 0},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.retryAfter != "" {
+				w.Header().Set("Retry-After", test.retryAfter)
+			}
+			if test.status == http.StatusFound {
+				w.Header().Set("Location", "/elsewhere")
+			}
+			w.WriteHeader(test.status)
+		}))
+		// The test server listens on loopback, which the real client refuses
+		client := *server.Client()
+		client.CheckRedirect = webhookClient.CheckRedirect
+		defaultClient := webhookClient
+		webhookClient = &client
+
+		err := SendWebhook(server.URL, "secret", 1, 2, []byte(`{}`))
+		webhookClient = defaultClient
+		server.Close()
+
+		if test.ok {
+			if err != nil {
+				t.Errorf("status %d: unexpected error %v", test.status, err)
+			}
+			continue
+		}
+		var deliveryErr *DeliveryError
+		if !errors.As(err, &deliveryErr) {
+			t.Fatalf("status %d: expected a DeliveryError, got %v", test.status, err)
+		}
+		if deliveryErr.Permanent != test.permanent || deliveryErr.RetryAfter != test.wait {
+			t.Errorf("status %d: got %+v", test.status, deliveryErr)
+		}
+	}
+}
+
+func TestSendWebhookRefusesInternalTargets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	var deliveryErr *DeliveryError
+	if !errors.As(SendWebhook(server.URL, "secret", 1, 2, []byte(`{}`)), &deliveryErr) || !deliveryErr.Permanent {
+		t.Errorf("expected a permanent failure for a loopback webhook, got %v", deliveryErr)
+	}
+	for _, target := range []string{"http://127.0.0.1/hook", "http://169.254.169.254/latest", "http://localhost:8080", "http://[::1]/"} {
+		if validateHTTPURL(target) == nil {
+			t.Errorf("expected %s to be rejected", target)
+		}
+	}
+	if err := validateHTTPURL("https://hooks.example.com/formy"); err != nil {
+		t.Errorf("public targets should pass, got %v", err)
+	}
+}
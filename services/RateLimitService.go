@@ -0,0 +1,150 @@
+package services
+
+import (
+	"core/config"
+	"core/models"
+	"core/utils"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	RATE_LIMIT_SUBMIT   = config.RateLimit{Requests: 30, Interval: time.Minute}
+	RATE_LIMIT_CAPTCHA  = config.RateLimit{Requests: 60, Interval: time.Minute}
+	RATE_LIMIT_API      = config.RateLimit{Requests: 120, Interval: time.Minute}
+	RATE_LIMIT_TELEGRAM = config.RateLimit{Requests: 600, Interval: time.Minute}
+	RATE_LIMIT_FORM     = config.RateLimit{Requests: 60, Interval: time.Minute}
+	RATE_LIMIT_OWNER    = config.RateLimit{Requests: 300, Interval: time.Minute}
+)
+
+// InitRateLimits reads the limits of every layer from the environment.
+func InitRateLimits() {
+	RATE_LIMIT_SUBMIT = config.GetEnvRateLimit("RATE_LIMIT_SUBMIT", RATE_LIMIT_SUBMIT)
+	RATE_LIMIT_CAPTCHA = config.GetEnvRateLimit("RATE_LIMIT_CAPTCHA", RATE_LIMIT_CAPTCHA)
+	RATE_LIMIT_API = config.GetEnvRateLimit("RATE_LIMIT_API", RATE_LIMIT_API)
+	RATE_LIMIT_TELEGRAM = config.GetEnvRateLimit("RATE_LIMIT_TELEGRAM", RATE_LIMIT_TELEGRAM)
+	RATE_LIMIT_FORM = config.GetEnvRateLimit("RATE_LIMIT_FORM", RATE_LIMIT_FORM)
+	RATE_LIMIT_OWNER = config.GetEnvRateLimit("RATE_LIMIT_OWNER", RATE_LIMIT_OWNER)
+	if isPostgresStore(utils.GetEnvString("RATE_LIMIT_STORE", STORE_MEMORY)) {
+		config.SetLimiterStore(&PostgresLimiterStore{DB: config.GetDB()})
+	}
+	config.OnRateLimitExceeded = func(ip string) {
+		Altcha.Record(ip, "", SIGNAL_RATE_LIMIT)
+	}
+}
+
+func GetFormRateLimit(formToken *models.FormToken) config.RateLimit {
+	if formToken.RateLimit != "" {
+		if limit, err := config.ParseRateLimit(formToken.RateLimit); err == nil {
+			return limit
+		}
+	}
+	return RATE_LIMIT_FORM
+}
+
+// AllowFormSubmission checks the budgets of the form and of its owner's
+// account, on top of the per IP limit of the submission routes.
+func AllowFormSubmission(formToken *models.FormToken) bool {
+	if !config.AllowRequest("form:"+formToken.Uuid.String(), GetFormRateLimit(formToken)) {
+		log.Printf("Middleware => Rate limit exceeded for form %s", formToken.Uuid)
+		return false
+	}
+	if !config.AllowRequest(fmt.Sprintf("owner:%d", formToken.UserID), RATE_LIMIT_OWNER) {
+		log.Printf("Middleware => Rate limit exceeded for owner %d", formToken.UserID)
+		return false
+	}
+	return true
+}
+
+// IsAdmin reports whether the Telegram user may see service-wide details such
+// as rate limiter stats. Admins are listed in ADMIN_TELEGRAM_IDS.
+func IsAdmin(telegramUserId uint64) bool {
+	return slices.Contains(utils.GetEnvList("ADMIN_TELEGRAM_IDS", nil), strconv.FormatUint(telegramUserId, 10))
+}
+
+func GetRateLimitStatsText() string {
+	stats, err := config.GetRateLimitStats()
+	if err != nil {
+		log.Println("Error reading rate limit stats:", err)
+		return "Rate limit stats are not available right now."
+	}
+	if stats.Store != "memory" {
+		return fmt.Sprintf("Store: %s\nActive windows: %d", stats.Store, stats.Size)
+	}
+	return fmt.Sprintf("Store: %s\nTracked keys: %d of %d\nHits: %d\nMisses: %d\nEvictions: %d\nExpired: %d",
+		stats.Store, stats.Size, stats.Capacity, stats.Hits, stats.Misses, stats.Evictions, stats.Expired)
+}
+
+// PostgresLimiterStore shares budgets between replicas with a sliding window
+// kept in the rate_limit_windows table. The count of the previous fixed window
+// is weighted by how much of it still overlaps the sliding window.
+type PostgresLimiterStore struct {
+	DB *gorm.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func (store *PostgresLimiterStore) Allow(key string, limit config.RateLimit) (bool, error) {
+	store.sweep()
+	window := newSlidingWindow(time.Now(), limit.Interval)
+	return models.TakeRateLimitWindow(
+		store.DB,
+		fmt.Sprintf("%s/%d:%s", limit.Interval, limit.Requests, key),
+		window.Start,
+		window.PreviousStart,
+		window.PreviousWeight,
+		limit.Requests,
+		window.ExpiresAt,
+	)
+}
+
+// slidingWindow locates now in the fixed windows of a limit, in Unix
+// milliseconds. PreviousWeight is the share of the previous window that still
+// overlaps the sliding window ending at now.
+type slidingWindow struct {
+	Start          int64
+	PreviousStart  int64
+	PreviousWeight float64
+	ExpiresAt      time.Time
+}
+
+func newSlidingWindow(now time.Time, interval time.Duration) slidingWindow {
+	length := max(interval.Milliseconds(), 1)
+	millis := now.UnixMilli()
+	start := millis - millis%length
+	return slidingWindow{
+		Start:          start,
+		PreviousStart:  start - length,
+		PreviousWeight: 1 - float64(millis-start)/float64(length),
+		// The window is still needed as the previous window of the next one
+		ExpiresAt: time.UnixMilli(start + 2*length),
+	}
+}
+
+func (store *PostgresLimiterStore) Stats() (config.LimiterStats, error) {
+	count, err := models.CountRateLimitWindows(store.DB)
+	if err != nil {
+		return config.LimiterStats{}, err
+	}
+	return config.LimiterStats{Store: "postgres", Size: int(count)}, nil
+}
+
+func (store *PostgresLimiterStore) sweep() {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if time.Since(store.lastSweep) < config.RATE_LIMIT_SWEEP_INTERVAL {
+		return
+	}
+	store.lastSweep = time.Now()
+	go func() {
+		if err := models.DeleteExpiredRateLimitWindows(store.DB); err != nil {
+			log.Println("Error deleting expired rate limit windows:", err)
+		}
+	}()
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"core/config"
+	"core/models"
+	"math"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestNewSlidingWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		now        time.Time
+		start      int64
+		prevWeight float64
+	}{
+		{"window start", time.UnixMilli(60_000), 60_000, 1},
+		{"quarter in", time.UnixMilli(75_000), 60_000, 0.75},
+		{"just before the end", time.UnixMilli(119_999), 60_000, 1.0 / 60_000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			window := newSlidingWindow(test.now, time.Minute)
+			if window.Start != test.start || window.PreviousStart != test.start-60_000 {
+				t.Errorf("window starts at %d after %d, want %d", window.Start, window.PreviousStart, test.start)
+			}
+			if math.Abs(window.PreviousWeight-test.prevWeight) > 1e-9 {
+				t.Errorf("previous weight = %v, want %v", window.PreviousWeight, test.prevWeight)
+			}
+			if !window.ExpiresAt.Equal(time.UnixMilli(test.start + 120_000)) {
+				t.Errorf("window expires at %v", window.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestNewSlidingWindowSubMillisecondInterval(t *testing.T) {
+	window := newSlidingWindow(time.UnixMilli(1234), time.Microsecond)
+	if window.Start != 1234 || window.PreviousStart != 1233 {
+		t.Errorf("unexpected window %+v", window)
+	}
+}
+
+// countingStore is a LimiterStore stand-in that allows a fixed number of
+// requests per key, the way a shared store counts for every replica.
+type countingStore struct {
+	counts map[string]int
+}
+
+func (store *countingStore) Allow(key string, limit config.RateLimit) (bool, error) {
+	store.counts[key]++
+	return store.counts[key] <= limit.Requests, nil
+}
+
+func (store *countingStore) Stats() (config.LimiterStats, error) {
+	return config.LimiterStats{Store: "counting", Size: len(store.counts)}, nil
+}
+
+func TestAllowFormSubmissionUsesTheConfiguredStore(t *testing.T) {
+	store := &countingStore{counts: map[string]int{}}
+	config.SetLimiterStore(store)
+	defer config.SetLimiterStore(nil)
+
+	formToken := &models.FormToken{UserID: 7, RateLimit: "2/1m"}
+	for i := 0; i < 2; i++ {
+		if !AllowFormSubmission(formToken) {
+			t.Fatalf("submission %d should be allowed", i+1)
+		}
+	}
+	if AllowFormSubmission(formToken) {
+		t.Error("the form's own limit should apply")
+	}
+	if store.counts["owner:7"] != 2 {
+		t.Errorf("the owner budget should only count submissions the form allowed, got %d", store.counts["owner:7"])
+	}
+}
+
+// openTestDatabase connects to the database in TEST_DATABASE_DSN, and skips
+// the test when it isn't set.
+func openTestDatabase(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.RateLimitWindow{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestPostgresLimiterStoreSharesBudgets(t *testing.T) {
+	// Two connections stand in for two replicas
+	first := &PostgresLimiterStore{DB: openTestDatabase(t)}
+	second := &PostgresLimiterStore{DB: openTestDatabase(t)}
+	key := "test:" + uuid.NewString()
+	t.Cleanup(func() {
+		first.DB.Where("key like ?", "%"+key).Delete(&models.RateLimitWindow{})
+	})
+	limit := config.RateLimit{Requests: 20, Interval: 24 * time.Hour}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	allowed := 0
+	for i := 0; i < 60; i++ {
+		store := first
+		if i%2 == 1 {
+			store = second
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := store.Allow(key, limit)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != limit.Requests {
+		t.Errorf("%d requests were allowed across both stores, want %d", allowed, limit.Requests)
+	}
+	if stats, err := second.Stats(); err != nil || stats.Store != "postgres" || stats.Size < 1 {
+		t.Errorf("stats = %+v, %v", stats, err)
+	}
+}
+
+func TestTakeRateLimitWindowWeighsThePreviousWindow(t *testing.T) {
+	db := openTestDatabase(t)
+	key := "test:" + uuid.NewString()
+	t.Cleanup(func() {
+		db.Where("key = ?", key).Delete(&models.RateLimitWindow{})
+	})
+	expiresAt := time.Now().Add(time.Hour)
+	previous := models.RateLimitWindow{Key: key, WindowStart: 0, Count: 10, ExpiresAt: expiresAt}
+	if err := db.Create(&previous).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Half of the previous ten requests still count, which leaves room for five
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		ok, err := models.TakeRateLimitWindow(db, key, 1000, 0, 0.5, 10, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("allowed = %d, want 5", allowed)
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"core/models"
+	"log"
+	"sync"
+	"time"
+)
+
+var REPLAY_SWEEP_INTERVAL = time.Minute
+
+const (
+	STORE_MEMORY   = "memory"
+	STORE_POSTGRES = "postgres"
+)
+
+// isPostgresStore reads the *_STORE settings. "database" is accepted as an
+// alias of "postgres", which ALTCHA_REPLAY_STORE used to expect.
+func isPostgresStore(kind string) bool {
+	return kind == STORE_POSTGRES || kind == "database"
+}
+
+// ReplayStore remembers consumed challenge signatures until they expire.
+// Consume reports false when the signature was already used.
+type ReplayStore interface {
+	Consume(signature string, expiresAt time.Time) (bool, error)
+}
+
+// MemoryReplayStore keeps signatures in process memory. Use DatabaseReplayStore
+// when several instances serve the same forms.
+type MemoryReplayStore struct {
+	mu        sync.Mutex
+	consumed  map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryReplayStore() *MemoryReplayStore {
+	return &MemoryReplayStore{consumed: map[string]time.Time{}, lastSweep: time.Now()}
+}
+
+func (store *MemoryReplayStore) Consume(signature string, expiresAt time.Time) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	now := time.Now()
+	if now.Sub(store.lastSweep) >= REPLAY_SWEEP_INTERVAL {
+		for key, expires := range store.consumed {
+			if now.After(expires) {
+				delete(store.consumed, key)
+			}
+		}
+		store.lastSweep = now
+	}
+	if expires, ok := store.consumed[signature]; ok && now.Before(expires) {
+		return false, nil
+	}
+	store.consumed[signature] = expiresAt
+	return true, nil
+}
+
+type DatabaseReplayStore struct {
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func (store *DatabaseReplayStore) Consume(signature string, expiresAt time.Time) (bool, error) {
+	store.mu.Lock()
+	if time.Since(store.lastSweep) >= REPLAY_SWEEP_INTERVAL {
+		store.lastSweep = time.Now()
+		go func() {
+			if err := models.DeleteExpiredChallenges(); err != nil {
+				log.Println("Error deleting expired challenges:", err)
+			}
+		}()
+	}
+	store.mu.Unlock()
+	return models.ConsumeChallenge(signature, expiresAt)
+}
+
+func NewReplayStore(kind string) ReplayStore {
+	if isPostgresStore(kind) {
+		return &DatabaseReplayStore{}
+	}
+	return NewMemoryReplayStore()
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"bytes"
+	"core/models"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SlackNotifier struct{}
+
+func (SlackNotifier) ValidateTarget(target string) error {
+	if !strings.HasPrefix(target, "https://") {
+		return fmt.Errorf("invalid slack webhook url %q", target)
+	}
+	return validateHTTPURL(target)
+}
+
+func (SlackNotifier) Format(formToken *models.FormToken, submission *models.Submission, target string) ([]models.Delivery, error) {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("*%s*\n", slackEscape(html.UnescapeString(GetSubmissionSubject(submission)))))
+	builder.WriteString(fmt.Sprintf("_Form %s_\n\n", slackEscape(formToken.Name)))
+	for _, key := range submission.OrderedKeys() {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		value := html.UnescapeString(strings.Join(submission.Fields.Values(key), ", "))
+		builder.WriteString(fmt.Sprintf("*%s:* %s\n", slackEscape(key), slackEscape(value)))
+	}
+	for _, file := range submission.Files {
+		builder.WriteString(fmt.Sprintf("*%s:* %s (%d bytes)\n", slackEscape(file.FieldName), slackEscape(file.FileName), file.Size))
+	}
+	body, err := json.Marshal(map[string]string{"text": builder.String()})
+	if err != nil {
+		return nil, err
+	}
+	return []models.Delivery{{Payload: string(body)}}, nil
+}
+
+func (SlackNotifier) Send(delivery *models.Delivery) error {
+	response, err := webhookClient.Post(delivery.Target, "application/json", bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return classifyWebhookRequestError(err)
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	// The body may echo anything, so it stays in the server log and only the status reaches the owner
+	log.Printf("Slack => Delivery %d failed with status %d: %s", delivery.ID, response.StatusCode, strings.TrimSpace(string(responseBody)))
+	deliveryErr := &DeliveryError{Err: fmt.Errorf("slack responded with status %d", response.StatusCode)}
+	if retryAfter, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && retryAfter > 0 {
+		deliveryErr.RetryAfter = time.Duration(retryAfter) * time.Second
+	}
+	// Slack answers 4xx for revoked hooks and invalid payloads, neither of which recover on retry
+	if response.StatusCode >= 400 && response.StatusCode < 500 && response.StatusCode != http.StatusTooManyRequests {
+		deliveryErr.Permanent = true
+	}
+	return deliveryErr
+}
+
+func slackEscape(text string) string {
+	replacer := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	return replacer.Replace(text)
+}
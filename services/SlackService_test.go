@@ -0,0 +1,62 @@
+package services
+
+import (
+	"core/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlackSendClassifiesResponses(t *testing.T) {
+	tests := []struct {
+		status     int
+		retryAfter string
+		permanent  bool
+		wait       time.Duration
+	}{
+		{http.StatusNotFound, "", true, 0},
+		{http.StatusTooManyRequests, "30", false, 30 * time.Second},
+		{http.StatusInternalServerError, "", false, 0},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.retryAfter != "" {
+				w.Header().Set("Retry-After", test.retryAfter)
+			}
+			w.WriteHeader(test.status)
+			w.Write([]byte("no_service"))
+		}))
+		// The test server listens on loopback, which the real client refuses
+		defaultClient := webhookClient
+		webhookClient = server.Client()
+
+		err := SlackNotifier{}.Send(&models.Delivery{ID: 1, Target: server.URL, Payload: `{"text":"hi"}`})
+		webhookClient = defaultClient
+		server.Close()
+
+		var deliveryErr *DeliveryError
+		if !errors.As(err, &deliveryErr) {
+			t.Fatalf("status %d: expected a DeliveryError, got %v", test.status, err)
+		}
+		if deliveryErr.Permanent != test.permanent || deliveryErr.RetryAfter != test.wait {
+			t.Errorf("status %d: got %+v", test.status, deliveryErr)
+		}
+		if strings.Contains(deliveryErr.Error(), "no_service") {
+			t.Errorf("status %d: the response body leaked into %q", test.status, deliveryErr.Error())
+		}
+	}
+}
+
+func TestSlackSendRefusesInternalTargets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var deliveryErr *DeliveryError
+	err := SlackNotifier{}.Send(&models.Delivery{ID: 1, Target: server.URL, Payload: `{"text":"hi"}`})
+	if !errors.As(err, &deliveryErr) || !deliveryErr.Permanent {
+		t.Errorf("expected a permanent failure for a loopback Slack hook, got %v", err)
+	}
+}
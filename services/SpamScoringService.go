@@ -0,0 +1,253 @@
+package services
+
+import (
+	"core/config"
+	"core/models"
+	"core/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	SPAM_DEFAULT_THRESHOLD   = 5
+	SPAM_MAX_LINKS           = 2
+	SPAM_DUPLICATE_WINDOW    = 24 * time.Hour
+	SPAM_DEFAULT_KEYWORDS    = []string{"viagra", "cialis", "casino", "crypto investment", "backlinks", "seo services", "payday loan"}
+	SPAM_DISPOSABLE_DOMAINS  = []string{"mailinator.com", "guerrillamail.com", "10minutemail.com", "tempmail.com", "temp-mail.org", "yopmail.com", "trashmail.com", "sharklasers.com", "getnada.com", "dispostable.com", "maildrop.cc"}
+	QUARANTINE_LIST_MAX_SIZE = 10
+)
+
+var linkRegex = regexp.MustCompile(`(?i)https?://|www\.`)
+
+// SpamScorer rates one aspect of a submission. Score returns the points it adds
+// to the submission's spam score, zero when nothing looks suspicious.
+type SpamScorer interface {
+	Name() string
+	Score(formToken *models.FormToken, submission *models.Submission) int
+}
+
+var spamScorers []SpamScorer
+
+func RegisterSpamScorer(scorer SpamScorer) {
+	spamScorers = append(spamScorers, scorer)
+}
+
+func init() {
+	RegisterSpamScorer(LinkScorer{})
+	RegisterSpamScorer(KeywordScorer{})
+	RegisterSpamScorer(DisposableEmailScorer{})
+	RegisterSpamScorer(DuplicateScorer{})
+	RegisterSpamScorer(ScriptMixingScorer{})
+}
+
+func GetSpamThreshold(formToken *models.FormToken) int {
+	if formToken.SpamThreshold != nil {
+		return *formToken.SpamThreshold
+	}
+	return utils.GetEnvInt("SPAM_THRESHOLD", SPAM_DEFAULT_THRESHOLD)
+}
+
+// ScoreSubmission runs every registered scorer and records the score on the
+// submission. It reports whether the submission should be quarantined.
+func ScoreSubmission(formToken *models.FormToken, submission *models.Submission) bool {
+	submission.PayloadHash = hashPayload(submission.Fields)
+	submission.SpamScore = 0
+	submission.SpamReasons = nil
+	threshold := GetSpamThreshold(formToken)
+	if threshold <= 0 {
+		return false
+	}
+	for _, scorer := range spamScorers {
+		if points := scorer.Score(formToken, submission); points > 0 {
+			submission.SpamScore += points
+			submission.SpamReasons = append(submission.SpamReasons, fmt.Sprintf("%s +%d", scorer.Name(), points))
+		}
+	}
+	return submission.SpamScore >= threshold
+}
+
+func hashPayload(fields models.JSONData) string {
+	// Marshaling a map sorts its keys, so equal payloads hash equally
+	payload, _ := json.Marshal(fields)
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// submissionTexts returns the unescaped values of the fields a visitor filled,
+// skipping special fields such as _next and _cc.
+func submissionTexts(submission *models.Submission) []string {
+	var texts []string
+	for _, key := range submission.OrderedKeys() {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		for _, value := range submission.Fields.Values(key) {
+			texts = append(texts, html.UnescapeString(value))
+		}
+	}
+	return texts
+}
+
+type LinkScorer struct{}
+
+func (LinkScorer) Name() string {
+	return "links"
+}
+
+func (LinkScorer) Score(formToken *models.FormToken, submission *models.Submission) int {
+	links := 0
+	for _, text := range submissionTexts(submission) {
+		links += len(linkRegex.FindAllStringIndex(text, -1))
+	}
+	maxLinks := utils.GetEnvInt("SPAM_MAX_LINKS", SPAM_MAX_LINKS)
+	if links <= maxLinks {
+		return 0
+	}
+	return min(2*(links-maxLinks), 6)
+}
+
+type KeywordScorer struct{}
+
+func (KeywordScorer) Name() string {
+	return "keywords"
+}
+
+func (KeywordScorer) Score(formToken *models.FormToken, submission *models.Submission) int {
+	keywords := utils.GetEnvList("SPAM_BLOCKED_KEYWORDS", SPAM_DEFAULT_KEYWORDS)
+	keywords = append(keywords, utils.SplitList(formToken.SpamKeywords)...)
+	text := strings.ToLower(strings.Join(submissionTexts(submission), "\n"))
+	points := 0
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(keyword); keyword != "" && strings.Contains(text, keyword) {
+			points += 3
+		}
+	}
+	return points
+}
+
+type DisposableEmailScorer struct{}
+
+func (DisposableEmailScorer) Name() string {
+	return "disposable_email"
+}
+
+func (DisposableEmailScorer) Score(formToken *models.FormToken, submission *models.Submission) int {
+	domains := utils.GetEnvList("SPAM_DISPOSABLE_DOMAINS", SPAM_DISPOSABLE_DOMAINS)
+	for _, text := range submissionTexts(submission) {
+		at := strings.LastIndex(text, "@")
+		if at < 0 || strings.ContainsAny(text, " \n") {
+			continue
+		}
+		emailDomain := strings.ToLower(strings.TrimSpace(text[at+1:]))
+		for _, domain := range domains {
+			if emailDomain == domain || strings.HasSuffix(emailDomain, "."+domain) {
+				return 4
+			}
+		}
+	}
+	return 0
+}
+
+type DuplicateScorer struct{}
+
+func (DuplicateScorer) Name() string {
+	return "duplicate"
+}
+
+func (DuplicateScorer) Score(formToken *models.FormToken, submission *models.Submission) int {
+	if submission.PayloadHash == "" {
+		return 0
+	}
+	since := time.Now().Add(-utils.GetEnvDuration("SPAM_DUPLICATE_WINDOW", SPAM_DUPLICATE_WINDOW))
+	count := models.CountSubmissionsByPayloadHash(formToken.Uuid, submission.PayloadHash, since)
+	return min(3*int(count), 6)
+}
+
+// ScriptMixingScorer catches words that mix Latin letters with Cyrillic or
+// Greek look-alikes, a common trick to slip past keyword filters.
+type ScriptMixingScorer struct{}
+
+func (ScriptMixingScorer) Name() string {
+	return "script_mixing"
+}
+
+func (ScriptMixingScorer) Score(formToken *models.FormToken, submission *models.Submission) int {
+	mixedWords := 0
+	for _, text := range submissionTexts(submission) {
+		words := strings.FieldsFunc(text, func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+		for _, word := range words {
+			var latin, lookalike bool
+			for _, r := range word {
+				switch {
+				case unicode.Is(unicode.Latin, r):
+					latin = true
+				case unicode.Is(unicode.Cyrillic, r), unicode.Is(unicode.Greek, r):
+					lookalike = true
+				}
+			}
+			if latin && lookalike {
+				mixedWords++
+			}
+		}
+	}
+	return min(2*mixedWords, 6)
+}
+
+func ReleaseSubmission(formToken *models.FormToken, submission *models.Submission) error {
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(submission).
+			Where("status = ?", models.SubmissionStatusQuarantined).
+			Update("status", models.SubmissionStatusPending)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("submission %d is not quarantined", submission.ID)
+		}
+		submission.Status = models.SubmissionStatusPending
+		return createDeliveries(tx, formToken, submission)
+	})
+	if err == nil {
+		WakeOutbox()
+	}
+	return err
+}
+
+func DiscardSubmission(submission *models.Submission) error {
+	if submission.Status != models.SubmissionStatusQuarantined {
+		return fmt.Errorf("submission %d is not quarantined", submission.ID)
+	}
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("submission_id = ?", submission.ID).Delete(&models.SubmissionFile{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(submission).Error
+	})
+	if err != nil {
+		return err
+	}
+	DeleteStoredFiles(submission.Files)
+	log.Printf("Spam => Discarded quarantined submission %d", submission.ID)
+	return nil
+}
+
+func GetQuarantineText(submission *models.Submission) string {
+	header := fmt.Sprintf("<b>Quarantined #%d</b>, score %d (%s)\n\n",
+		submission.ID, submission.SpamScore, html.EscapeString(strings.Join(submission.SpamReasons, ", ")))
+	body := createTelegramBody(GetSubmissionSubject(submission), submission)
+	if len(body) == 0 {
+		return header
+	}
+	return splitTelegramHTML(header+body[0], TELEGRAM_MESSAGE_LIMIT)[0]
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"core/models"
+	"core/utils"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	HONEYPOT_DEFAULT_FIELD     = "_gotcha"
+	TIMESTAMP_FIELD            = "_ts"
+	DEFAULT_MIN_SUBMIT_SECONDS = 3
+	TIMESTAMP_MAX_AGE          = 24 * time.Hour
+)
+
+// timestampKey returns the key render timestamps are signed with. Without one
+// anybody could sign a timestamp, so none are issued or checked.
+func timestampKey() string {
+	return utils.GetEnvString("FORM_TIMESTAMP_KEY", os.Getenv("ALTCHA_HMAC_KEY"))
+}
+
+// signTimestamp binds the timestamp to one form, so a harvested value can't
+// be reused on other forms.
+func signTimestamp(key string, formUuid uuid.UUID, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(formUuid.String()))
+	mac.Write([]byte("."))
+	mac.Write([]byte(timestamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateRenderTimestamp returns a signed timestamp for the form, or false
+// when no signing key is configured.
+func CreateRenderTimestamp(formUuid uuid.UUID) (string, bool) {
+	key := timestampKey()
+	if key == "" {
+		return "", false
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return timestamp + "." + signTimestamp(key, formUuid, timestamp), true
+}
+
+func TimestampHandler(c *gin.Context) {
+	formUuid := utils.GetUUIDFromString(c.Query("form"))
+	if formUuid == uuid.Nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The form query parameter must be a form token."})
+		return
+	}
+	value, ok := CreateRenderTimestamp(formUuid)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Render timestamps are not configured."})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"name": TIMESTAMP_FIELD, "value": value})
+}
+
+func GetHoneypotField(formToken *models.FormToken) string {
+	if formToken.HoneypotField != "" {
+		return formToken.HoneypotField
+	}
+	return HONEYPOT_DEFAULT_FIELD
+}
+
+func GetMinSubmitSeconds(formToken *models.FormToken) int {
+	if formToken.MinSubmitSeconds != nil {
+		return *formToken.MinSubmitSeconds
+	}
+	return utils.GetEnvInt("FORM_MIN_SUBMIT_SECONDS", DEFAULT_MIN_SUBMIT_SECONDS)
+}
+
+// CheckSpamHeuristics returns the reason a submission should be dropped, or an
+// empty string. The honeypot and timestamp fields are removed from the data.
+// A missing timestamp is only spam when the owner configured a minimum fill
+// time for the form, as forms that never fetched one would be dropped otherwise.
+// Timestamps aren't checked at all while no signing key is configured.
+func CheckSpamHeuristics(formToken *models.FormToken, fields models.JSONData) string {
+	honeypotField := GetHoneypotField(formToken)
+	honeypotValue := strings.TrimSpace(strings.Join(fields.Values(honeypotField), ""))
+	_, hasTimestamp := fields[TIMESTAMP_FIELD]
+	timestampValue := fields.String(TIMESTAMP_FIELD)
+	delete(fields, honeypotField)
+	delete(fields, TIMESTAMP_FIELD)
+
+	if honeypotValue != "" {
+		return models.SpamReasonHoneypot
+	}
+	key := timestampKey()
+	if key == "" {
+		return ""
+	}
+	if !hasTimestamp {
+		if formToken.MinSubmitSeconds != nil && *formToken.MinSubmitSeconds > 0 {
+			return models.SpamReasonMissingTimestamp
+		}
+		return ""
+	}
+	timestamp, signature, found := strings.Cut(timestampValue, ".")
+	renderedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if !found || err != nil || !hmac.Equal([]byte(signature), []byte(signTimestamp(key, formToken.Uuid, timestamp))) {
+		return models.SpamReasonInvalidTimestamp
+	}
+	age := time.Since(time.Unix(renderedAt, 0))
+	if age > utils.GetEnvDuration("FORM_TIMESTAMP_MAX_AGE", TIMESTAMP_MAX_AGE) {
+		return models.SpamReasonExpiredTimestamp
+	}
+	if age < time.Duration(GetMinSubmitSeconds(formToken))*time.Second {
+		return models.SpamReasonTooFast
+	}
+	return ""
+}
+
+func RecordBlockedSubmission(formToken *models.FormToken, reason string) {
+	log.Printf("Spam => Blocked submission to form %s: %s", formToken.Uuid, reason)
+	if err := models.IncrementSpamCounter(formToken.Uuid, reason); err != nil {
+		log.Println("Error counting blocked submission:", err)
+	}
+}
+
+func GetSpamStatsText(formToken *models.FormToken) string {
+	counters := models.GetSpamCounters(formToken.Uuid)
+	if len(counters) == 0 {
+		return "No spam has been blocked for this form yet."
+	}
+	var builder strings.Builder
+	var total int64
+	for _, counter := range counters {
+		total += counter.Count
+		builder.WriteString(fmt.Sprintf("%s: %d (last %s)\n", counter.Reason, counter.Count, counter.UpdatedAt.Format("2006-01-02 15:04")))
+	}
+	return fmt.Sprintf("Blocked submissions: %d\n\n%s", total, builder.String())
+}
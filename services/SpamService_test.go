@@ -0,0 +1,82 @@
+package services
+
+import (
+	"core/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func timestampRequest(formUuid uuid.UUID) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/timestamp?form="+formUuid.String(), nil)
+	TimestampHandler(c)
+	return recorder
+}
+
+func TestTimestampsNeedAKey(t *testing.T) {
+	t.Setenv("FORM_TIMESTAMP_KEY", "")
+	t.Setenv("ALTCHA_HMAC_KEY", "")
+	minSeconds := 5
+	formToken := &models.FormToken{Uuid: uuid.New(), MinSubmitSeconds: &minSeconds}
+
+	if _, ok := CreateRenderTimestamp(formToken.Uuid); ok {
+		t.Error("no timestamp should be issued without a key")
+	}
+	if recorder := timestampRequest(formToken.Uuid); recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+
+	// A timestamp signed with the empty key must not be trusted either
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	forged := now + "." + signTimestamp("", formToken.Uuid, now)
+	for _, fields := range []models.JSONData{{"_ts": forged}, {}} {
+		if reason := CheckSpamHeuristics(formToken, fields); reason != "" {
+			t.Errorf("timestamps should not be checked without a key, got %q", reason)
+		}
+		if _, ok := fields["_ts"]; ok {
+			t.Error("the timestamp field should still be removed")
+		}
+	}
+	if reason := CheckSpamHeuristics(formToken, models.JSONData{"_gotcha": "bot"}); reason != models.SpamReasonHoneypot {
+		t.Errorf("the honeypot should still work without a key, got %q", reason)
+	}
+}
+
+func TestCheckSpamHeuristicsTimestamps(t *testing.T) {
+	t.Setenv("FORM_TIMESTAMP_KEY", "secret")
+	minSeconds := 5
+	formToken := &models.FormToken{Uuid: uuid.New(), MinSubmitSeconds: &minSeconds}
+	signed := func(formUuid uuid.UUID, renderedAt time.Time) string {
+		timestamp := strconv.FormatInt(renderedAt.Unix(), 10)
+		return timestamp + "." + signTimestamp("secret", formUuid, timestamp)
+	}
+
+	if recorder := timestampRequest(formToken.Uuid); recorder.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	tests := []struct {
+		name   string
+		fields models.JSONData
+		reason string
+	}{
+		{"valid", models.JSONData{"_ts": signed(formToken.Uuid, time.Now().Add(-time.Minute))}, ""},
+		{"too fast", models.JSONData{"_ts": signed(formToken.Uuid, time.Now())}, models.SpamReasonTooFast},
+		{"expired", models.JSONData{"_ts": signed(formToken.Uuid, time.Now().Add(-48*time.Hour))}, models.SpamReasonExpiredTimestamp},
+		{"other form", models.JSONData{"_ts": signed(uuid.New(), time.Now().Add(-time.Minute))}, models.SpamReasonInvalidTimestamp},
+		{"garbage", models.JSONData{"_ts": "12345"}, models.SpamReasonInvalidTimestamp},
+		{"missing", models.JSONData{}, models.SpamReasonMissingTimestamp},
+	}
+	for _, test := range tests {
+		if reason := CheckSpamHeuristics(formToken, test.fields); reason != test.reason {
+			t.Errorf("%s: reason = %q, want %q", test.name, reason, test.reason)
+		}
+	}
+}
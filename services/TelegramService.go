@@ -1,15 +1,25 @@
 package services
 
 import (
+	"core/models"
+	"errors"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"html"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 var (
 	Bot   *tgbotapi.BotAPI
 	Token string
+
+	TELEGRAM_MESSAGE_LIMIT = 4096
+	TELEGRAM_HTML_RESERVE  = 64
 )
 
 func InitTelegram() {
@@ -32,8 +42,220 @@ func InitTelegram() {
 	}
 }
 
-func SendTelegramMessage(to int64, body string) {
+func SendTelegramMessage(to int64, body string) error {
 	msg := tgbotapi.NewMessage(to, body)
 	msg.ParseMode = "html"
-	Bot.Send(msg)
+	_, err := Bot.Send(msg)
+	if err != nil {
+		log.Printf("Error sending telegram message to %d: %v", to, err)
+	}
+	return err
+}
+
+func SendTelegramFile(to int64, file *models.SubmissionFile, caption string) error {
+	content, err := Files.Open(file.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	reader := tgbotapi.FileReader{Name: file.FileName, Reader: content}
+	var chattable tgbotapi.Chattable
+	// Telegram only accepts photos up to 10 MB, larger images are sent as documents
+	if strings.HasPrefix(file.MimeType, "image/") && file.MimeType != "image/gif" && file.Size <= 10<<20 {
+		photo := tgbotapi.NewPhoto(to, reader)
+		photo.Caption = caption
+		photo.ParseMode = "html"
+		chattable = photo
+	} else {
+		document := tgbotapi.NewDocument(to, reader)
+		document.Caption = caption
+		document.ParseMode = "html"
+		chattable = document
+	}
+	_, err = Bot.Send(chattable)
+	if err != nil {
+		log.Printf("Error sending telegram file to %d: %v", to, err)
+	}
+	return err
+}
+
+type TelegramNotifier struct{}
+
+func (TelegramNotifier) ValidateTarget(target string) error {
+	if _, err := strconv.ParseInt(target, 10, 64); err != nil {
+		return fmt.Errorf("invalid telegram chat id %q", target)
+	}
+	return nil
+}
+
+func (TelegramNotifier) Format(formToken *models.FormToken, submission *models.Submission, target string) ([]models.Delivery, error) {
+	var deliveries []models.Delivery
+	for _, message := range createTelegramBody(GetSubmissionSubject(submission), submission) {
+		deliveries = append(deliveries, models.Delivery{Payload: message})
+	}
+	for i := range submission.Files {
+		file := submission.Files[i]
+		deliveries = append(deliveries, models.Delivery{
+			Payload:          fmt.Sprintf("<b>#%s:</b> %s", html.EscapeString(file.FieldName), html.EscapeString(file.FileName)),
+			SubmissionFileID: &file.ID,
+		})
+	}
+	return deliveries, nil
+}
+
+func (TelegramNotifier) Send(delivery *models.Delivery) error {
+	chatID, err := strconv.ParseInt(delivery.Target, 10, 64)
+	if err != nil {
+		return &DeliveryError{Err: fmt.Errorf("invalid telegram chat id %q", delivery.Target), Permanent: true}
+	}
+	if delivery.SubmissionFileID != nil {
+		file, err := models.GetSubmissionFileById(*delivery.SubmissionFileID)
+		if err != nil {
+			return &DeliveryError{Err: err, Permanent: true}
+		}
+		return classifyTelegramError(SendTelegramFile(chatID, file, delivery.Payload))
+	}
+	return classifyTelegramError(SendTelegramMessage(chatID, delivery.Payload))
+}
+
+func classifyTelegramError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return &DeliveryError{Err: err}
+	}
+	// Flood control only delays the message, so the attempt doesn't count
+	if apiErr.RetryAfter > 0 || apiErr.Code == 429 {
+		return &DeliveryError{Err: err, Throttled: true, RetryAfter: time.Duration(apiErr.RetryAfter) * time.Second}
+	}
+	// Bad requests (chat not found, malformed message) and blocked bots won't succeed on retry
+	if apiErr.Code >= 400 && apiErr.Code < 500 {
+		return &DeliveryError{Err: err, Permanent: true}
+	}
+	return &DeliveryError{Err: err}
+}
+
+func formatTelegramValues(values []string) string {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return "• " + strings.Join(values, "\n• ")
+}
+
+func createTelegramBody(subject string, submission *models.Submission) []string {
+	var messageBuilder strings.Builder
+	messageBuilder.WriteString(fmt.Sprintf("<b>%s</b>\n\n", subject))
+	var hashtags []string
+	messageBuilder.WriteString("Submitted fields:\n")
+	keys := submission.OrderedKeys()
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "_") {
+			hashtags = append(hashtags, "#"+strings.ReplaceAll(html.EscapeString(key), " ", "_"))
+		}
+	}
+	messageBuilder.WriteString(strings.Join(hashtags, " ") + "\n\n")
+	for _, key := range keys {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		messageBuilder.WriteString(fmt.Sprintf("<b>#%s:</b>\n%s\n\n", html.EscapeString(key), formatTelegramValues(submission.Fields.Values(key))))
+	}
+	return splitTelegramHTML(messageBuilder.String(), TELEGRAM_MESSAGE_LIMIT)
+}
+
+// splitTelegramHTML cuts an HTML message into parts of at most limit bytes.
+// It prefers line breaks and otherwise cuts between runes outside tags and
+// entities, closing elements left open and reopening them in the next part,
+// because Telegram rejects a part whose markup doesn't parse.
+func splitTelegramHTML(text string, limit int) []string {
+	var messages []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			messages = append(messages, current.String())
+			current.Reset()
+		}
+	}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if current.Len()+len(line) > limit {
+			flush()
+		}
+		for len(line) > limit {
+			part, rest := cutTelegramHTML(line, limit)
+			messages = append(messages, part)
+			line = rest
+		}
+		current.WriteString(line)
+	}
+	flush()
+	return messages
+}
+
+// cutTelegramHTML splits one line that is longer than limit.
+func cutTelegramHTML(line string, limit int) (string, string) {
+	// Leave room for the closing tags added to the first part
+	cut := max(limit-TELEGRAM_HTML_RESERVE, 1)
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	if lt, gt := strings.LastIndex(line[:cut], "<"), strings.LastIndex(line[:cut], ">"); lt > gt {
+		cut = lt
+	}
+	if amp, semi := strings.LastIndex(line[:cut], "&"), strings.LastIndex(line[:cut], ";"); amp > semi {
+		cut = amp
+	}
+	if cut == 0 {
+		// A single tag or entity longer than the limit, nothing sensible is left
+		cut = limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		return line[:cut], line[cut:]
+	}
+	openTags := openHTMLTags(line[:cut])
+	part := line[:cut]
+	rest := line[cut:]
+	for i := len(openTags) - 1; i >= 0; i-- {
+		part += "</" + htmlTagName(openTags[i]) + ">"
+	}
+	return part, strings.Join(openTags, "") + rest
+}
+
+// openHTMLTags returns the opening tags in text that are not closed yet.
+func openHTMLTags(text string) []string {
+	var open []string
+	for {
+		start := strings.Index(text, "<")
+		if start < 0 {
+			return open
+		}
+		end := strings.Index(text[start:], ">")
+		if end < 0 {
+			return open
+		}
+		tag := text[start : start+end+1]
+		text = text[start+end+1:]
+		if strings.HasPrefix(tag, "</") {
+			name := htmlTagName(tag)
+			for i := len(open) - 1; i >= 0; i-- {
+				if htmlTagName(open[i]) == name {
+					open = append(open[:i], open[i+1:]...)
+					break
+				}
+			}
+		} else if !strings.HasSuffix(tag, "/>") {
+			open = append(open, tag)
+		}
+	}
+}
+
+func htmlTagName(tag string) string {
+	name := strings.TrimLeft(tag, "</")
+	if end := strings.IndexAny(name, " >/"); end >= 0 {
+		name = name[:end]
+	}
+	return strings.ToLower(name)
 }
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitTelegramHTMLKeepsShortMessages(t *testing.T) {
+	text := "<b>Subject</b>\n\nSubmitted fields:\n"
+	messages := splitTelegramHTML(text, 100)
+	if len(messages) != 1 || messages[0] != text {
+		t.Fatalf("expected the message unchanged, got %q", messages)
+	}
+}
+
+func TestSplitTelegramHTMLPrefersLineBreaks(t *testing.T) {
+	text := strings.Repeat("a", 30) + "\n" + strings.Repeat("b", 30) + "\n"
+	messages := splitTelegramHTML(text, 40)
+	if len(messages) != 2 || messages[0] != strings.Repeat("a", 30)+"\n" {
+		t.Fatalf("expected a split at the line break, got %q", messages)
+	}
+}
+
+func TestSplitTelegramHTMLLongLines(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"multibyte runes", strings.Repeat("ж", 300)},
+		{"entities", strings.Repeat("&amp;&lt;", 100)},
+		{"open element", "<b>" + strings.Repeat("x", 300) + "</b>"},
+		{"tags", strings.Repeat("<b>#x:</b> ", 60)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages := splitTelegramHTML(test.line, 100)
+			if len(messages) < 2 {
+				t.Fatalf("expected several parts, got %d", len(messages))
+			}
+			for _, message := range messages {
+				if len(message) > 100 {
+					t.Errorf("part is %d bytes long", len(message))
+				}
+				if !utf8.ValidString(message) {
+					t.Errorf("part %q cuts a rune", message)
+				}
+				if len(openHTMLTags(message)) != 0 {
+					t.Errorf("part %q leaves a tag open", message)
+				}
+				if amp, semi := strings.LastIndex(message, "&"), strings.LastIndex(message, ";"); amp > semi {
+					t.Errorf("part %q cuts an entity", message)
+				}
+				if lt, gt := strings.LastIndex(message, "<"), strings.LastIndex(message, ">"); lt > gt {
+					t.Errorf("part %q cuts a tag", message)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitTelegramHTMLReopensElements(t *testing.T) {
+	messages := splitTelegramHTML("<b>"+strings.Repeat("x", 150)+"</b>", 100)
+	if !strings.HasSuffix(messages[0], "</b>") || !strings.HasPrefix(messages[1], "<b>") {
+		t.Fatalf("expected the bold element to be closed and reopened, got %q", messages)
+	}
+}
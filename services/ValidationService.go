@@ -0,0 +1,172 @@
+package services
+
+import (
+	"core/models"
+	"encoding/json"
+	"fmt"
+	"github.com/asaskevich/govalidator"
+	"html"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var phoneRegex = regexp.MustCompile(`^\+?[0-9 ().-]{7,20}$`)
+
+// decimalRegex keeps out the hex, underscore, NaN and Inf forms that
+// strconv.ParseFloat would otherwise accept.
+var decimalRegex = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+type FieldRule struct {
+	Required  bool     `json:"required,omitempty"`
+	Type      string   `json:"type,omitempty"`
+	MinLength *int     `json:"min_length,omitempty"`
+	MaxLength *int     `json:"max_length,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Enum      []string `json:"enum,omitempty"`
+	Message   string   `json:"message,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+type ValidationSchema map[string]*FieldRule
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func ParseValidationSchema(text string) (ValidationSchema, error) {
+	schema := ValidationSchema{}
+	if strings.TrimSpace(text) == "" {
+		return schema, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&schema); err != nil {
+		return nil, err
+	}
+	for field, rule := range schema {
+		if rule == nil {
+			return nil, fmt.Errorf("rule of %s is empty", field)
+		}
+		switch rule.Type {
+		case "", "text", "email", "phone", "url", "number", "integer":
+		default:
+			return nil, fmt.Errorf("unknown type %q for %s", rule.Type, field)
+		}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for %s: %v", field, err)
+			}
+			rule.pattern = pattern
+		}
+	}
+	return schema, nil
+}
+
+func ValidateSubmission(formToken *models.FormToken, fields models.JSONData) []FieldError {
+	schema, err := ParseValidationSchema(formToken.ValidationSchema)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fieldErrors []FieldError
+	for _, name := range names {
+		rule := schema[name]
+		var values []string
+		for _, value := range fields.Values(name) {
+			if value = strings.TrimSpace(html.UnescapeString(value)); value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			if rule.Required {
+				fieldErrors = append(fieldErrors, FieldError{Field: name, Message: rule.errorMessage("This field is required.")})
+			}
+			continue
+		}
+		for _, value := range values {
+			if message := rule.check(value); message != "" {
+				fieldErrors = append(fieldErrors, FieldError{Field: name, Message: rule.errorMessage(message)})
+				break
+			}
+		}
+	}
+	return fieldErrors
+}
+
+func (rule *FieldRule) errorMessage(defaultMessage string) string {
+	if rule.Message != "" {
+		return rule.Message
+	}
+	return defaultMessage
+}
+
+func (rule *FieldRule) check(value string) string {
+	switch rule.Type {
+	case "email":
+		if !govalidator.IsEmail(value) {
+			return "Enter a valid email address."
+		}
+	case "phone":
+		if !phoneRegex.MatchString(value) {
+			return "Enter a valid phone number."
+		}
+	case "url":
+		if !govalidator.IsURL(value) {
+			return "Enter a valid URL."
+		}
+	case "number", "integer":
+		if !decimalRegex.MatchString(value) {
+			return fmt.Sprintf("Enter a valid %s.", rule.Type)
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) ||
+			(rule.Type == "integer" && !isInteger(number)) {
+			return fmt.Sprintf("Enter a valid %s.", rule.Type)
+		}
+		if rule.Min != nil && number < *rule.Min {
+			return fmt.Sprintf("Must be at least %v.", *rule.Min)
+		}
+		if rule.Max != nil && number > *rule.Max {
+			return fmt.Sprintf("Must be at most %v.", *rule.Max)
+		}
+	}
+	length := utf8.RuneCountInString(value)
+	if rule.MinLength != nil && length < *rule.MinLength {
+		return fmt.Sprintf("Must be at least %d characters.", *rule.MinLength)
+	}
+	if rule.MaxLength != nil && length > *rule.MaxLength {
+		return fmt.Sprintf("Must be at most %d characters.", *rule.MaxLength)
+	}
+	if rule.pattern != nil && !rule.pattern.MatchString(value) {
+		return "Has an invalid format."
+	}
+	if len(rule.Enum) > 0 {
+		for _, option := range rule.Enum {
+			if option == value {
+				return ""
+			}
+		}
+		return "Must be one of: " + strings.Join(rule.Enum, ", ") + "."
+	}
+	return ""
+}
+
+// isInteger reports whether number is whole and fits in an int64. Converting
+// a float outside that range to int64 is implementation-defined.
+func isInteger(number float64) bool {
+	return math.Trunc(number) == number && number >= -1<<63 && number < 1<<63
+}
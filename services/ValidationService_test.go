@@ -0,0 +1,119 @@
+package services
+
+import (
+	"core/models"
+	"testing"
+)
+
+func validate(t *testing.T, schema string, fields models.JSONData) map[string]string {
+	t.Helper()
+	if _, err := ParseValidationSchema(schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	errorsByField := map[string]string{}
+	for _, fieldError := range ValidateSubmission(&models.FormToken{ValidationSchema: schema}, fields) {
+		errorsByField[fieldError.Field] = fieldError.Message
+	}
+	return errorsByField
+}
+
+func TestValidateSubmissionNumberBounds(t *testing.T) {
+	schema := `{"age": {"type": "integer", "min": 18, "max": 99}, "price": {"type": "number", "min": 0.5, "max": 10}}`
+	tests := []struct {
+		field   string
+		value   string
+		message string
+	}{
+		{"age", "18", ""},
+		{"age", "99", ""},
+		{"age", "17", "Must be at least 18."},
+		{"age", "100", "Must be at most 99."},
+		{"age", "20.5", "Enter a valid integer."},
+		{"age", "1e1", "Must be at least 18."},
+		{"price", "0.5", ""},
+		{"price", ".75", ""},
+		{"price", "+10", ""},
+		{"price", "0.4", "Must be at least 0.5."},
+		{"price", "10.01", "Must be at most 10."},
+		{"price", "NaN", "Enter a valid number."},
+		{"price", "nan", "Enter a valid number."},
+		{"price", "Inf", "Enter a valid number."},
+		{"price", "-Infinity", "Enter a valid number."},
+		{"price", "1e400", "Enter a valid number."},
+		{"price", "0x5", "Enter a valid number."},
+		{"price", "0x1p-2", "Enter a valid number."},
+		{"price", "1_0", "Enter a valid number."},
+		{"price", "5 apples", "Enter a valid number."},
+	}
+	for _, test := range tests {
+		errorsByField := validate(t, schema, models.JSONData{test.field: test.value})
+		if errorsByField[test.field] != test.message {
+			t.Errorf("%s = %q: got %q, want %q", test.field, test.value, errorsByField[test.field], test.message)
+		}
+	}
+}
+
+func TestValidateSubmissionLargeIntegers(t *testing.T) {
+	schema := `{"count": {"type": "integer"}}`
+	tests := []struct {
+		value   string
+		message string
+	}{
+		{"1e18", ""},
+		{"-9223372036854775808", ""},
+		{"9223372036854775808", "Enter a valid integer."},
+		{"1e30", "Enter a valid integer."},
+		{"-1e19", "Enter a valid integer."},
+		{"1e308", "Enter a valid integer."},
+	}
+	for _, test := range tests {
+		errorsByField := validate(t, schema, models.JSONData{"count": test.value})
+		if errorsByField["count"] != test.message {
+			t.Errorf("count = %q: got %q, want %q", test.value, errorsByField["count"], test.message)
+		}
+	}
+}
+
+func TestValidateSubmissionRules(t *testing.T) {
+	schema := `{
+		"email": {"required": true, "type": "email"},
+		"name": {"min_length": 2, "max_length": 5},
+		"plan": {"enum": ["free", "pro"], "message": "Pick a plan."},
+		"code": {"pattern": "^[A-Z]{3}$"}
+	}`
+	errorsByField := validate(t, schema, models.JSONData{"name": "Zoë", "plan": "pro", "code": "ABC"})
+	if len(errorsByField) != 1 || errorsByField["email"] != "This field is required." {
+		t.Errorf("expected only the missing email, got %v", errorsByField)
+	}
+
+	errorsByField = validate(t, schema, models.JSONData{
+		"email": "not-an-email",
+		"name":  "Alexander",
+		"plan":  "enterprise",
+		"code":  "abc",
+	})
+	expected := map[string]string{
+		"email": "Enter a valid email address.",
+		"name":  "Must be at most 5 characters.",
+		"plan":  "Pick a plan.",
+		"code":  "Has an invalid format.",
+	}
+	for field, message := range expected {
+		if errorsByField[field] != message {
+			t.Errorf("%s: got %q, want %q", field, errorsByField[field], message)
+		}
+	}
+}
+
+func TestParseValidationSchemaRejectsInvalidSchemas(t *testing.T) {
+	for _, schema := range []string{
+		`{"a": {"type": "date"}}`,
+		`{"a": {"pattern": "("}}`,
+		`{"a": {"unknown": true}}`,
+		`{"a": null}`,
+	} {
+		if _, err := ParseValidationSchema(schema); err == nil {
+			t.Errorf("expected %s to be rejected", schema)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"bytes"
+	"core/models"
+	"core/utils"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Webhook targets are chosen by form owners, so deliveries only connect to
+// public addresses and don't follow redirects.
+var webhookClient = utils.NewPublicHTTPClient(10 * time.Second)
+
+func GenerateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// classifyWebhookRequestError retries network errors, except for targets that
+// resolve to non-public addresses, which won't become public on retry.
+func classifyWebhookRequestError(err error) error {
+	return &DeliveryError{Err: err, Permanent: errors.Is(err, utils.ErrNonPublicAddress)}
+}
+
+func SendWebhook(url string, secret string, deliveryID uint64, submissionID uint64, body []byte) error {
+	timestamp := time.Now().Unix()
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &DeliveryError{Err: err, Permanent: true}
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Formy-Webhook/1.0")
+	request.Header.Set("X-Formy-Delivery", strconv.FormatUint(deliveryID, 10))
+	request.Header.Set("X-Formy-Submission", strconv.FormatUint(submissionID, 10))
+	request.Header.Set("X-Formy-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Formy-Signature", fmt.Sprintf("t=%d,v1=%s", timestamp, SignWebhookPayload(secret, timestamp, body)))
+
+	response, err := webhookClient.Do(request)
+	if err != nil {
+		return classifyWebhookRequestError(err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	deliveryErr := &DeliveryError{Err: fmt.Errorf("webhook responded with status %d", response.StatusCode)}
+	if retryAfter, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && retryAfter > 0 {
+		deliveryErr.RetryAfter = time.Duration(retryAfter) * time.Second
+	}
+	// The endpoint is gone for good, there is no point in retrying
+	if response.StatusCode == http.StatusGone {
+		deliveryErr.Permanent = true
+	}
+	return deliveryErr
+}
+
+type WebhookNotifier struct{}
+
+func (WebhookNotifier) ValidateTarget(target string) error {
+	return validateHTTPURL(target)
+}
+
+func (WebhookNotifier) Format(formToken *models.FormToken, submission *models.Submission, target string) ([]models.Delivery, error) {
+	fields := map[string]interface{}{}
+	for key, value := range submission.Fields {
+		values := submission.Fields.Values(key)
+		for i := range values {
+			values[i] = html.UnescapeString(values[i])
+		}
+		if _, ok := value.(string); ok {
+			fields[key] = values[0]
+		} else {
+			fields[key] = values
+		}
+	}
+	files := []map[string]interface{}{}
+	for _, file := range submission.Files {
+		files = append(files, map[string]interface{}{
+			"field":     file.FieldName,
+			"name":      file.FileName,
+			"mime_type": file.MimeType,
+			"size":      file.Size,
+		})
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"id":           submission.ID,
+		"form":         formToken.Uuid,
+		"form_name":    formToken.Name,
+		"fields":       fields,
+		"field_order":  submission.OrderedKeys(),
+		"files":        files,
+		"origin":       submission.Origin,
+		"submitted_at": submission.CreatedAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []models.Delivery{{Payload: string(body)}}, nil
+}
+
+func (WebhookNotifier) Send(delivery *models.Delivery) error {
+	submission, err := models.GetSubmissionById(delivery.SubmissionID)
+	if err != nil {
+		return &DeliveryError{Err: err}
+	}
+	formToken, err := models.GetFormTokenByUuid(submission.FormTokenUuid)
+	if err != nil {
+		return &DeliveryError{Err: fmt.Errorf("form token %s not found", submission.FormTokenUuid), Permanent: true}
+	}
+	return SendWebhook(delivery.Target, formToken.WebhookSecret, delivery.ID, submission.ID, []byte(delivery.Payload))
+}
+
+func validateHTTPURL(target string) error {
+	parsedUrl, err := url.Parse(target)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		return fmt.Errorf("invalid url %q", target)
+	}
+	// Catch obvious internal targets early, the client checks resolved addresses too
+	host := parsedUrl.Hostname()
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && !utils.IsPublicIP(ip)) {
+		return fmt.Errorf("url %q doesn't point to a public address", target)
+	}
+	return nil
+}
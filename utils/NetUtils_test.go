@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":         true,
+		"2001:4860::8888": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.0.0.1":        false,
+		"172.16.5.4":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"224.0.0.1":       false,
+		"::ffff:10.0.0.1": false,
+	}
+	for value, expected := range tests {
+		if IsPublicIP(net.ParseIP(value)) != expected {
+			t.Errorf("IsPublicIP(%s) = %v, want %v", value, !expected, expected)
+		}
+	}
+	if IsPublicIP(nil) {
+		t.Error("a missing IP is not public")
+	}
+}
+
+func TestPublicHTTPClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, err := NewPublicHTTPClient(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Errorf("expected ErrNonPublicAddress, got %v", err)
+	}
+}
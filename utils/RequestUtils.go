@@ -6,16 +6,31 @@ import (
 )
 
 func GetRequestOrigin(c *gin.Context) string {
-	referer := c.Request.Header.Get("Referer")
-	if referer == "" {
-		referer = c.Request.Header.Get("Origin")
-	}
-	if referer == "" {
+	originUrl := GetRequestOriginURL(c)
+	if originUrl == nil {
 		return ""
 	}
-	parsedUrl, err := url.Parse(referer)
-	if err != nil {
-		return ""
+	return originUrl.Hostname()
+}
+
+// GetRequestOriginURL prefers the Origin header, which browsers set themselves,
+// over the Referer.
+func GetRequestOriginURL(c *gin.Context) *url.URL {
+	if origin := ParseOriginURL(c.Request.Header.Get("Origin")); origin != nil {
+		return origin
+	}
+	return ParseOriginURL(c.Request.Header.Get("Referer"))
+}
+
+// ParseOriginURL parses an Origin or Referer header value, returning nil for
+// empty, opaque ("null") or malformed values.
+func ParseOriginURL(value string) *url.URL {
+	if value == "" || value == "null" {
+		return nil
+	}
+	parsedUrl, err := url.Parse(value)
+	if err != nil || parsedUrl.Hostname() == "" {
+		return nil
 	}
-	return parsedUrl.Hostname()
+	return parsedUrl
 }